@@ -2,8 +2,16 @@ package graph
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strings"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/BetterGR/api-gateway/graph/model"
+	"github.com/golang-jwt/jwt/v5"
 )
 
 // Key for storing auth token in context
@@ -12,29 +20,232 @@ type contextKey string
 const (
 	// AuthTokenKey is the key used to store the auth token in the context
 	AuthTokenKey = contextKey("auth_token")
+
+	// ClaimsKey is the key used to store the verified token claims in the context
+	ClaimsKey = contextKey("claims")
+
+	// tokenLeeway tolerates small clock differences between the gateway and Keycloak
+	tokenLeeway = 30 * time.Second
 )
 
-// AuthMiddleware extracts the JWT token from the Authorization header and adds it to the context
-func AuthMiddleware(next http.Handler) http.Handler {
+// Claims are the claims of a Keycloak access token that the gateway cares about
+type Claims struct {
+	jwt.RegisteredClaims
+
+	PreferredUsername string `json:"preferred_username,omitempty"`
+	Email             string `json:"email,omitempty"`
+
+	// Realm and client roles assigned to the user
+	RealmAccess    RoleSet            `json:"realm_access,omitempty"`
+	ResourceAccess map[string]RoleSet `json:"resource_access,omitempty"`
+
+	// clientID is the gateway's own Keycloak client, whose roles count next to the realm roles
+	clientID string
+}
+
+// RoleSet is a list of roles as found in Keycloak's realm_access and resource_access claims
+type RoleSet struct {
+	Roles []string `json:"roles"`
+}
+
+// AuthConfig holds the settings used to verify access tokens
+type AuthConfig struct {
+	// JWKSURL is where the realm publishes its signing keys
+	JWKSURL string
+	// Issuer is the expected iss claim
+	Issuer string
+	// Audience is the expected aud claim
+	Audience string
+	// ClientID is the gateway's Keycloak client, only its client roles are granted
+	ClientID string
+}
+
+// LoadAuthConfig builds the auth configuration from the environment. It returns nil
+// when KEYCLOAK_URL is not set, in which case tokens are forwarded unverified.
+func LoadAuthConfig() (*AuthConfig, error) {
+	keycloakURL := strings.TrimRight(getEnvOrDefault("KEYCLOAK_URL", ""), "/")
+	if keycloakURL == "" {
+		return nil, nil
+	}
+
+	// Tokens issued to any other client of the realm must not be accepted
+	audience := getEnvOrDefault("KEYCLOAK_AUDIENCE", "")
+	if audience == "" {
+		return nil, errors.New("KEYCLOAK_AUDIENCE must be set when KEYCLOAK_URL is set")
+	}
+
+	// An empty client ID, as left by the .env example, falls back to the audience as well
+	clientID := getEnvOrDefault("KEYCLOAK_CLIENT_ID", "")
+	if clientID == "" {
+		clientID = audience
+	}
+
+	realm := getEnvOrDefault("KEYCLOAK_REALM", "betterGR")
+	realmURL := keycloakURL + "/realms/" + realm
+
+	return &AuthConfig{
+		JWKSURL:  realmURL + "/protocol/openid-connect/certs",
+		Issuer:   getEnvOrDefault("KEYCLOAK_ISSUER", realmURL),
+		Audience: audience,
+		ClientID: clientID,
+	}, nil
+}
+
+// TokenValidator verifies the signature, issuer, audience and expiry of access tokens
+type TokenValidator struct {
+	jwks     *JWKS
+	parser   *jwt.Parser
+	clientID string
+}
+
+// NewTokenValidator creates a validator for the given configuration. The HTTP client is
+// used to fetch the JWKS, a default client is used when it is nil.
+func NewTokenValidator(cfg AuthConfig, client *http.Client) *TokenValidator {
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}),
+		jwt.WithIssuer(cfg.Issuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(tokenLeeway),
+		jwt.WithAudience(cfg.Audience),
+	}
+
+	return &TokenValidator{
+		jwks:     NewJWKS(cfg.JWKSURL, client),
+		parser:   jwt.NewParser(opts...),
+		clientID: cfg.ClientID,
+	}
+}
+
+// Validate parses and verifies a raw access token and returns its claims. Signing keys that
+// must be fetched first are fetched within ctx.
+func (v *TokenValidator) Validate(ctx context.Context, token string) (*Claims, error) {
+	claims := &Claims{}
+	if _, err := v.parser.ParseWithClaims(token, claims, v.jwks.Keyfunc(ctx)); err != nil {
+		return nil, err
+	}
+	claims.clientID = v.clientID
+
+	return claims, nil
+}
+
+// AuthMiddleware extracts the JWT token from the Authorization header, verifies it and adds
+// the token and its claims to the context. Requests carrying an invalid token are rejected
+// with 401 before reaching any resolver; requests without a token continue anonymously.
+// When validator is nil the token is forwarded without verification.
+func AuthMiddleware(validator *TokenValidator, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Extract the token from the Authorization header
 		authHeader := r.Header.Get("Authorization")
-		if authHeader != "" {
-			// Usually the format is "Bearer token" - split to get the token part
-			parts := strings.Split(authHeader, " ")
-			if len(parts) == 2 {
-				token := parts[1]
-				// Store the token in context for resolvers to use
-				ctx := context.WithValue(r.Context(), AuthTokenKey, token)
-				r = r.WithContext(ctx)
-			}
+		if authHeader == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token, err := parseBearerToken(authHeader)
+		if err != nil {
+			writeUnauthorized(w, err)
+			return
+		}
+
+		ctx, err := authenticate(r.Context(), validator, token)
+		if err != nil {
+			writeUnauthorized(w, err)
+			return
 		}
 
 		// Call the next handler with the updated context
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequireRole guards plain HTTP endpoints, such as the admin endpoints, that are not
+// protected by schema directives. It must run behind AuthMiddleware.
+func RequireRole(role model.Role, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims := GetClaims(r.Context())
+		if claims == nil {
+			writeUnauthorized(w, errors.New("no token was presented"))
+			return
+		}
+
+		if !claims.HasRole(role) {
+			writeJSON(w, http.StatusForbidden, map[string]any{
+				"errors": []map[string]any{{
+					"message":    "you are not allowed to access this endpoint",
+					"extensions": map[string]any{"code": ErrCodeForbidden},
+				}},
+			})
+			return
+		}
+
 		next.ServeHTTP(w, r)
 	})
 }
 
+// WebsocketInitFunc authenticates subscriptions. Browsers cannot set headers on WebSocket
+// upgrade requests, so the token is taken from the "Authorization" entry of the
+// connection_init payload instead. Connections without a token continue anonymously.
+func WebsocketInitFunc(validator *TokenValidator) transport.WebsocketInitFunc {
+	return func(ctx context.Context, initPayload transport.InitPayload) (context.Context, *transport.InitPayload, error) {
+		authHeader := initPayload.Authorization()
+		if authHeader == "" {
+			return ctx, nil, nil
+		}
+
+		token, err := parseBearerToken(authHeader)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		ctx, err = authenticate(ctx, validator, token)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid access token: %w", err)
+		}
+
+		return ctx, nil, nil
+	}
+}
+
+// authenticate verifies token and stores it, along with its claims, in the context
+func authenticate(ctx context.Context, validator *TokenValidator, token string) (context.Context, error) {
+	if validator != nil {
+		claims, err := validator.Validate(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+		ctx = context.WithValue(ctx, ClaimsKey, claims)
+		ctx = annotateRequest(ctx, "subject", claims.Subject)
+	}
+
+	// Store the token in context for resolvers to forward to the microservices
+	return context.WithValue(ctx, AuthTokenKey, token), nil
+}
+
+// parseBearerToken extracts the token from a "Bearer <token>" header value
+func parseBearerToken(authHeader string) (string, error) {
+	scheme, token, ok := strings.Cut(authHeader, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return "", errors.New("authorization header must use the Bearer scheme")
+	}
+
+	return strings.TrimSpace(token), nil
+}
+
+// writeUnauthorized responds with 401 and a GraphQL-shaped error body
+func writeUnauthorized(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+	w.WriteHeader(http.StatusUnauthorized)
+
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"errors": []map[string]any{{
+			"message":    fmt.Sprintf("invalid access token: %v", err),
+			"extensions": map[string]any{"code": ErrCodeUnauthenticated},
+		}},
+	})
+}
+
 // GetAuthToken gets the auth token from the GraphQL context
 func GetAuthToken(ctx context.Context) string {
 	if token, ok := ctx.Value(AuthTokenKey).(string); ok {
@@ -42,3 +253,11 @@ func GetAuthToken(ctx context.Context) string {
 	}
 	return ""
 }
+
+// GetClaims gets the verified token claims from the context, nil for anonymous requests
+func GetClaims(ctx context.Context) *Claims {
+	if claims, ok := ctx.Value(ClaimsKey).(*Claims); ok {
+		return claims
+	}
+	return nil
+}
@@ -0,0 +1,233 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/BetterGR/api-gateway/graph/model"
+	"github.com/gorilla/websocket"
+)
+
+func TestHasRoleOnlyGrantsRealmAndGatewayClientRoles(t *testing.T) {
+	keys := newTestKeyServer(t, "k1")
+	validator := keys.validator()
+
+	tests := []struct {
+		name   string
+		claims func(*Claims)
+		want   bool
+	}{
+		{"realm role", func(c *Claims) { c.RealmAccess.Roles = []string{"admin"} }, true},
+		{"role of the gateway's client", func(c *Claims) {
+			c.RealmAccess.Roles = nil
+			c.ResourceAccess = map[string]RoleSet{testAudience: {Roles: []string{"ADMIN"}}}
+		}, true},
+		{"role of another client", func(c *Claims) {
+			c.RealmAccess.Roles = nil
+			c.ResourceAccess = map[string]RoleSet{"grafana": {Roles: []string{"admin"}}}
+		}, false},
+		{"other role", func(c *Claims) { c.RealmAccess.Roles = []string{"staff"} }, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := validClaims("u1")
+			tt.claims(claims)
+
+			validated, err := validator.Validate(context.Background(), keys.sign(t, "k1", claims))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := validated.HasRole(model.RoleAdmin); got != tt.want {
+				t.Errorf("HasRole(ADMIN) = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadAuthConfig(t *testing.T) {
+	t.Setenv("KEYCLOAK_URL", "")
+	if cfg, err := LoadAuthConfig(); cfg != nil || err != nil {
+		t.Errorf("without KEYCLOAK_URL: got %+v, %v, want no configuration", cfg, err)
+	}
+
+	t.Setenv("KEYCLOAK_URL", "https://keycloak.test/")
+	t.Setenv("KEYCLOAK_AUDIENCE", "")
+	if _, err := LoadAuthConfig(); err == nil {
+		t.Error("accepted KEYCLOAK_URL without KEYCLOAK_AUDIENCE")
+	}
+
+	t.Setenv("KEYCLOAK_AUDIENCE", "api-gateway")
+	cfg, err := LoadAuthConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Issuer != "https://keycloak.test/realms/betterGR" || cfg.Audience != "api-gateway" || cfg.ClientID != "api-gateway" {
+		t.Errorf("got %+v", cfg)
+	}
+
+	for value, want := range map[string]string{"": "api-gateway", "gateway-roles": "gateway-roles"} {
+		t.Setenv("KEYCLOAK_CLIENT_ID", value)
+		cfg, err := LoadAuthConfig()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.ClientID != want {
+			t.Errorf("KEYCLOAK_CLIENT_ID=%q: got client ID %q, want %q", value, cfg.ClientID, want)
+		}
+	}
+}
+
+// wsMessage is a message of the graphql-transport-ws protocol
+type wsMessage struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// dialSubscriptions opens a graphql-transport-ws connection to a server over r, from another
+// origin, and sends connection_init with initPayload
+func dialSubscriptions(t *testing.T, r *Resolver, validator *TokenValidator, initPayload map[string]any) *websocket.Conn {
+	t.Helper()
+
+	srv := handler.New(NewExecutableSchema(Config{Resolvers: r, Directives: NewDirectives()}))
+	srv.AddTransport(transport.Websocket{
+		InitFunc: WebsocketInitFunc(validator),
+		Upgrader: websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }},
+	})
+	srv.SetErrorPresenter(NewErrorPresenter(false))
+	srv.Use(NewLoaderExtension(r))
+	server := httptest.NewServer(AuthMiddleware(validator, srv))
+	t.Cleanup(server.Close)
+
+	dialer := websocket.Dialer{Subprotocols: []string{"graphql-transport-ws"}}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), http.Header{"Origin": {"https://elsewhere.test"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	payload, err := json.Marshal(initPayload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.WriteJSON(wsMessage{Type: "connection_init", Payload: payload}); err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+// readMessage returns the next message of conn
+func readMessage(t *testing.T, conn *websocket.Conn) (wsMessage, error) {
+	t.Helper()
+
+	var msg wsMessage
+	if err := conn.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	err := conn.ReadJSON(&msg)
+	return msg, err
+}
+
+// subscribeGrades subscribes to the grades of s1 over conn
+func subscribeGrades(t *testing.T, conn *websocket.Conn) {
+	t.Helper()
+
+	payload, err := json.Marshal(map[string]any{"query": `subscription { gradePublished(studentId: "s1", semester: "2025-spring") { gradeValue } }`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.WriteJSON(wsMessage{ID: "1", Type: "subscribe", Payload: payload}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSubscriptionsRequireAValidConnectionInitToken(t *testing.T) {
+	keys := newTestKeyServer(t, "k1")
+
+	tests := []struct {
+		name        string
+		initPayload map[string]any
+	}{
+		{name: "invalid token", initPayload: map[string]any{"Authorization": "Bearer not-a-token"}},
+		{name: "token signed with an unknown key", initPayload: map[string]any{"Authorization": "Bearer " + keys.sign(t, "k2", validClaims("s1"))}},
+		{name: "not a bearer token", initPayload: map[string]any{"Authorization": "Basic czE6cGFzc3dvcmQ="}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := dialSubscriptions(t, newFakeSchool().resolver(), keys.validator(), tt.initPayload)
+
+			// The connection is closed without being acknowledged
+			msg, err := readMessage(t, conn)
+			if err == nil {
+				t.Fatalf("got %s message, want the connection closed", msg.Type)
+			}
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				t.Errorf("got %v, want the connection closed", err)
+			}
+		})
+	}
+
+	t.Run("no token", func(t *testing.T) {
+		conn := dialSubscriptions(t, newFakeSchool().resolver(), keys.validator(), nil)
+		if msg, err := readMessage(t, conn); err != nil || msg.Type != "connection_ack" {
+			t.Fatalf("got %+v, %v, want connection_ack", msg, err)
+		}
+
+		// Anonymous connections cannot subscribe to protected fields
+		subscribeGrades(t, conn)
+		msg, err := readMessage(t, conn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var res graphQLResponse
+		if err := json.Unmarshal(msg.Payload, &res); err != nil {
+			t.Fatal(err)
+		}
+		if codes := res.errorCodes(); msg.Type != "next" || len(codes) != 1 || codes[0] != ErrCodeUnauthenticated {
+			t.Errorf("got %s message %s, want an UNAUTHENTICATED error", msg.Type, msg.Payload)
+		}
+	})
+
+	t.Run("valid token", func(t *testing.T) {
+		r := newFakeSchool().resolver()
+		conn := dialSubscriptions(t, r, keys.validator(), map[string]any{"Authorization": "Bearer " + keys.sign(t, "k1", validClaims("s1"))})
+		if msg, err := readMessage(t, conn); err != nil || msg.Type != "connection_ack" {
+			t.Fatalf("got %+v, %v, want connection_ack", msg, err)
+		}
+		subscribeGrades(t, conn)
+
+		// Publish until the subscription is registered and the grade delivered
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			ticker := time.NewTicker(10 * time.Millisecond)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-done:
+					return
+				case <-ticker.C:
+					r.publishEvent(context.Background(), gradesTopic("s1", "2025-spring"),
+						&model.Grade{ID: "g1", StudentID: "s1", CourseID: "c1", Semester: "2025-spring", GradeValue: "80"})
+				}
+			}
+		}()
+
+		msg, err := readMessage(t, conn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg.Type != "next" || strings.Contains(string(msg.Payload), `"errors"`) || !strings.Contains(string(msg.Payload), `"gradeValue":"80"`) {
+			t.Errorf("got %s message %s, want the grade", msg.Type, msg.Payload)
+		}
+	})
+}
@@ -0,0 +1,39 @@
+// Package blobstore keeps the files submitted for homework. Uploads are streamed into the
+// store rather than buffered in memory, and their size and SHA-256 checksum are computed as
+// they are written, so that submissions can record them. Callers choose the key a file is
+// stored under and keep the reference returned by Put to open or delete it later.
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// ErrNotFound is returned when a reference does not point to a stored object
+var ErrNotFound = errors.New("blobstore: object not found")
+
+// ErrInvalidKey is returned when a key cannot be used to store an object
+var ErrInvalidKey = errors.New("blobstore: invalid key")
+
+// Object describes a stored file
+type Object struct {
+	// Ref is the reference to pass to Open and Delete
+	Ref string
+	// Size is the number of bytes stored
+	Size int64
+	// Checksum is the hex-encoded SHA-256 digest of the content
+	Checksum string
+}
+
+// Store keeps uploaded files
+type Store interface {
+	// Put streams content into the store under key and returns the stored object
+	Put(ctx context.Context, key string, content io.Reader) (Object, error)
+
+	// Open returns the content of a stored object
+	Open(ctx context.Context, ref string) (io.ReadCloser, error)
+
+	// Delete removes a stored object. Deleting a missing object is not an error.
+	Delete(ctx context.Context, ref string) error
+}
@@ -0,0 +1,155 @@
+package blobstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// failingReader returns content, then fails as a dropped connection would
+type failingReader struct {
+	content io.Reader
+}
+
+func (r failingReader) Read(p []byte) (int, error) {
+	n, err := r.content.Read(p)
+	if errors.Is(err, io.EOF) {
+		return n, errors.New("connection reset")
+	}
+	return n, err
+}
+
+// storedFiles lists the files below dir, temporary ones included
+func storedFiles(t *testing.T, dir string) []string {
+	t.Helper()
+
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			files = append(files, path)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestLocalStorePutStreamsAndChecksums(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content := strings.Repeat("homework ", 100_000)
+	obj, err := store.Put(context.Background(), "submissions/h1/s1/answer.pdf", strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sum := sha256.Sum256([]byte(content))
+	if obj.Ref != "submissions/h1/s1/answer.pdf" || obj.Size != int64(len(content)) || obj.Checksum != hex.EncodeToString(sum[:]) {
+		t.Errorf("got %+v, want the key, a size of %d and the SHA-256 of the content", obj, len(content))
+	}
+
+	f, err := store.Open(context.Background(), obj.Ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	stored, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(stored) != content {
+		t.Error("stored content differs from the upload")
+	}
+}
+
+func TestLocalStoreRejectsKeysOutsideItsDirectory(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewLocalStore(filepath.Join(dir, "blobs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"../escaped", "submissions/../../escaped", "/etc/passwd", ""} {
+		if _, err := store.Put(context.Background(), key, strings.NewReader("x")); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Put(%q): got %v, want ErrInvalidKey", key, err)
+		}
+		if _, err := store.Open(context.Background(), key); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Open(%q): got %v, want ErrInvalidKey", key, err)
+		}
+		if err := store.Delete(context.Background(), key); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Delete(%q): got %v, want ErrInvalidKey", key, err)
+		}
+	}
+
+	if files := storedFiles(t, dir); len(files) != 0 {
+		t.Errorf("got files %v, want none", files)
+	}
+}
+
+func TestLocalStoreRemovesPartialFiles(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		content io.Reader
+	}{
+		{name: "failed read", ctx: context.Background(), content: failingReader{strings.NewReader(strings.Repeat("x", 100_000))}},
+		{name: "cancelled upload", ctx: cancelled, content: strings.NewReader("x")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			store, err := NewLocalStore(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := store.Put(tt.ctx, "submissions/answer.pdf", tt.content); err == nil {
+				t.Fatal("Put succeeded")
+			}
+			if files := storedFiles(t, dir); len(files) != 0 {
+				t.Errorf("got files %v, want the partial upload removed", files)
+			}
+		})
+	}
+}
+
+func TestLocalStoreMissingObjects(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.Open(context.Background(), "missing.pdf"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Open: got %v, want ErrNotFound", err)
+	}
+	if err := store.Delete(context.Background(), "missing.pdf"); err != nil {
+		t.Errorf("Delete: got %v, want no error", err)
+	}
+
+	obj, err := store.Put(context.Background(), "answer.pdf", strings.NewReader("x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete(context.Background(), obj.Ref); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(store.root, "answer.pdf")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("got %v, want the file deleted", err)
+	}
+}
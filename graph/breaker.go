@@ -0,0 +1,155 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sony/gobreaker/v2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// BreakerSettings configures the circuit breakers guarding the microservices
+type BreakerSettings struct {
+	// FailureThreshold is the number of consecutive failures that opens a breaker
+	FailureThreshold uint32
+	// OpenTimeout is how long a breaker stays open before letting trial calls through
+	OpenTimeout time.Duration
+	// HalfOpenRequests is the number of trial calls allowed while half-open
+	HalfOpenRequests uint32
+}
+
+// LoadBreakerSettings builds the circuit breaker settings from the environment
+func LoadBreakerSettings() (BreakerSettings, error) {
+	threshold, err := strconv.ParseUint(getEnvOrDefault("BREAKER_FAILURE_THRESHOLD", "5"), 10, 32)
+	if err != nil || threshold == 0 {
+		return BreakerSettings{}, errors.New("BREAKER_FAILURE_THRESHOLD must be a positive number")
+	}
+
+	openTimeout, err := time.ParseDuration(getEnvOrDefault("BREAKER_OPEN_TIMEOUT", "30s"))
+	if err != nil || openTimeout <= 0 {
+		return BreakerSettings{}, errors.New("BREAKER_OPEN_TIMEOUT must be a positive duration such as 30s")
+	}
+
+	halfOpenRequests, err := strconv.ParseUint(getEnvOrDefault("BREAKER_HALF_OPEN_REQUESTS", "1"), 10, 32)
+	if err != nil || halfOpenRequests == 0 {
+		return BreakerSettings{}, errors.New("BREAKER_HALF_OPEN_REQUESTS must be a positive number")
+	}
+
+	return BreakerSettings{
+		FailureThreshold: uint32(threshold),
+		OpenTimeout:      openTimeout,
+		HalfOpenRequests: uint32(halfOpenRequests),
+	}, nil
+}
+
+// Breakers holds one circuit breaker per microservice. A breaker opens once its service
+// keeps failing, so that calls fail fast instead of waiting for timeouts, and lets a few
+// trial calls through after OpenTimeout to find out whether the service has recovered.
+type Breakers struct {
+	services []string
+	breakers map[string]*gobreaker.CircuitBreaker[struct{}]
+}
+
+// BreakerState is the state of a circuit breaker as reported by the admin endpoint
+type BreakerState struct {
+	Service              string `json:"service"`
+	State                string `json:"state"`
+	Requests             uint32 `json:"requests"`
+	TotalFailures        uint32 `json:"totalFailures"`
+	ConsecutiveFailures  uint32 `json:"consecutiveFailures"`
+	ConsecutiveSuccesses uint32 `json:"consecutiveSuccesses"`
+}
+
+// NewBreakers creates a closed circuit breaker for each of the given services, logging the
+// changes of their state with logger
+func NewBreakers(services []string, settings BreakerSettings, logger *slog.Logger) *Breakers {
+	b := &Breakers{
+		services: services,
+		breakers: make(map[string]*gobreaker.CircuitBreaker[struct{}], len(services)),
+	}
+
+	for _, service := range services {
+		b.breakers[service] = gobreaker.NewCircuitBreaker[struct{}](gobreaker.Settings{
+			Name:        service,
+			MaxRequests: settings.HalfOpenRequests,
+			Timeout:     settings.OpenTimeout,
+			ReadyToTrip: func(counts gobreaker.Counts) bool {
+				return counts.ConsecutiveFailures >= settings.FailureThreshold
+			},
+			IsSuccessful: func(err error) bool {
+				return !isServiceFailure(err)
+			},
+			// Callers giving up say nothing about the health of the service
+			IsExcluded: func(err error) bool {
+				return status.Code(err) == codes.Canceled
+			},
+			OnStateChange: func(name string, from, to gobreaker.State) {
+				logger.Warn("circuit breaker changed state", "service", name, "from", from.String(), "to", to.String())
+			},
+		})
+	}
+
+	return b
+}
+
+// interceptor fails calls fast while the breaker of service is open
+func (b *Breakers) interceptor(service string) grpc.UnaryClientInterceptor {
+	cb := b.breakers[service]
+
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if cb == nil {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		_, err := cb.Execute(func() (struct{}, error) {
+			return struct{}{}, invoker(ctx, method, req, reply, cc, opts...)
+		})
+		if errors.Is(err, gobreaker.ErrOpenState) || errors.Is(err, gobreaker.ErrTooManyRequests) {
+			return status.Error(codes.Unavailable, fmt.Sprintf("the %s service is unavailable: %v", service, err))
+		}
+
+		return err
+	}
+}
+
+// States returns the current state of every breaker
+func (b *Breakers) States() []BreakerState {
+	states := make([]BreakerState, 0, len(b.services))
+	for _, service := range b.services {
+		cb := b.breakers[service]
+		counts := cb.Counts()
+		states = append(states, BreakerState{
+			Service:              service,
+			State:                cb.State().String(),
+			Requests:             counts.Requests,
+			TotalFailures:        counts.TotalFailures,
+			ConsecutiveFailures:  counts.ConsecutiveFailures,
+			ConsecutiveSuccesses: counts.ConsecutiveSuccesses,
+		})
+	}
+
+	return states
+}
+
+// ServeHTTP reports the state of every breaker as JSON
+func (b *Breakers) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{"breakers": b.States()})
+}
+
+// isServiceFailure reports whether an error means the service itself is unhealthy, as
+// opposed to the request being rejected
+func isServiceFailure(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Internal, codes.Unknown, codes.DataLoss:
+		return true
+	default:
+		return false
+	}
+}
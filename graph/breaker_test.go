@@ -0,0 +1,119 @@
+package graph
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeInvoker stands for a microservice answering every call with err
+type fakeInvoker struct {
+	err   error
+	calls int
+}
+
+func (f *fakeInvoker) invoke(context.Context, string, any, any, *grpc.ClientConn, ...grpc.CallOption) error {
+	f.calls++
+	return f.err
+}
+
+// testBreakers guards the students service with a breaker opening after 3 failures
+func testBreakers() *Breakers {
+	return NewBreakers([]string{"students"}, BreakerSettings{
+		FailureThreshold: 3,
+		OpenTimeout:      50 * time.Millisecond,
+		HalfOpenRequests: 1,
+	}, slog.New(slog.DiscardHandler))
+}
+
+// breakerState returns the state of the breaker of the students service
+func breakerState(b *Breakers) string {
+	return b.States()[0].State
+}
+
+func TestBreakerOpensAndFailsFast(t *testing.T) {
+	b := testBreakers()
+	call := b.interceptor("students")
+	backend := &fakeInvoker{err: status.Error(codes.Unavailable, "connection refused")}
+
+	for i := range 3 {
+		if err := call(context.Background(), "/students/GetStudent", nil, nil, nil, backend.invoke); status.Code(err) != codes.Unavailable {
+			t.Fatalf("call %d: got %v, want the error of the service", i+1, err)
+		}
+	}
+	if state := breakerState(b); state != "open" {
+		t.Fatalf("after 3 failures: got state %s, want open", state)
+	}
+
+	// Calls fail fast without reaching the service, with the code of an unavailable service
+	err := call(context.Background(), "/students/GetStudent", nil, nil, nil, backend.invoke)
+	if backend.calls != 3 {
+		t.Errorf("got %d calls to the service, want 3", backend.calls)
+	}
+	if code := grpcErrorCodes[status.Code(err)]; code != ErrCodeServiceUnavailable {
+		t.Errorf("got %v, want a %s error", err, ErrCodeServiceUnavailable)
+	}
+}
+
+func TestBreakerIgnoresRejectedRequests(t *testing.T) {
+	b := testBreakers()
+	call := b.interceptor("students")
+
+	for _, err := range []error{
+		status.Error(codes.NotFound, "student not found"),
+		status.Error(codes.PermissionDenied, "forbidden"),
+		status.Error(codes.InvalidArgument, "invalid id"),
+		status.Error(codes.Canceled, "context canceled"),
+	} {
+		backend := &fakeInvoker{err: err}
+		for range 3 {
+			_ = call(context.Background(), "/students/GetStudent", nil, nil, nil, backend.invoke)
+		}
+	}
+
+	if state := breakerState(b); state != "closed" {
+		t.Errorf("got state %s, want closed", state)
+	}
+}
+
+func TestBreakerLetsAProbeThroughWhenHalfOpen(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		probeErr  error
+		wantState string
+	}{
+		{name: "service recovered", wantState: "closed"},
+		{name: "service still failing", probeErr: status.Error(codes.Unavailable, "connection refused"), wantState: "open"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			b := testBreakers()
+			call := b.interceptor("students")
+
+			failing := &fakeInvoker{err: status.Error(codes.Unavailable, "connection refused")}
+			for range 3 {
+				_ = call(context.Background(), "/students/GetStudent", nil, nil, nil, failing.invoke)
+			}
+
+			time.Sleep(60 * time.Millisecond)
+			if state := breakerState(b); state != "half-open" {
+				t.Fatalf("after the open timeout: got state %s, want half-open", state)
+			}
+
+			probe := &fakeInvoker{err: tt.probeErr}
+			if err := call(context.Background(), "/students/GetStudent", nil, nil, nil, probe.invoke); err != tt.probeErr {
+				t.Errorf("probe: got %v, want %v", err, tt.probeErr)
+			}
+			if probe.calls != 1 {
+				t.Errorf("got %d probe calls to the service, want 1", probe.calls)
+			}
+			if state := breakerState(b); state != tt.wantState {
+				t.Errorf("after the probe: got state %s, want %s", state, tt.wantState)
+			}
+		})
+	}
+}
@@ -0,0 +1,268 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	// requiredServices are the microservices the gateway cannot serve without
+	requiredServices = []string{"students", "staff", "courses", "grades"}
+
+	// optionalServices are the microservices only some fields depend on, they don't gate readiness
+	optionalServices = []string{homeworkService}
+
+	// backendServices are the microservices the gateway calls, as named in errors and settings
+	backendServices = append(slices.Clone(requiredServices), optionalServices...)
+)
+
+// CallPolicy configures the deadlines, retries and hedging of the calls made to a microservice.
+// Only idempotent reads are retried or hedged, writes are always attempted once.
+type CallPolicy struct {
+	// Timeout bounds each attempt, no deadline is added when zero
+	Timeout time.Duration
+	// MaxAttempts is the number of attempts made for a read, including the first one
+	MaxAttempts int
+	// InitialBackoff is the largest delay before the first retry, doubling for each further retry
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between retries
+	MaxBackoff time.Duration
+	// HedgeDelay, when set, makes reads send another attempt whenever the previous one has not
+	// answered within that delay instead of waiting for it to fail
+	HedgeDelay time.Duration
+}
+
+// defaultCallPolicy applies to services without specific settings
+var defaultCallPolicy = CallPolicy{
+	Timeout:        5 * time.Second,
+	MaxAttempts:    3,
+	InitialBackoff: 100 * time.Millisecond,
+	MaxBackoff:     2 * time.Second,
+}
+
+// LoadCallPolicies builds the call policy of every microservice from the environment.
+// GRPC_TIMEOUT, GRPC_MAX_ATTEMPTS, GRPC_INITIAL_BACKOFF, GRPC_MAX_BACKOFF and GRPC_HEDGE_DELAY
+// set the defaults, which can be overridden per service by prefixing them with the service
+// name, e.g. GRADES_GRPC_TIMEOUT.
+func LoadCallPolicies() (map[string]CallPolicy, error) {
+	defaults, err := loadCallPolicy("GRPC_", defaultCallPolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	policies := make(map[string]CallPolicy, len(backendServices))
+	for _, service := range backendServices {
+		policy, err := loadCallPolicy(strings.ToUpper(service)+"_GRPC_", defaults)
+		if err != nil {
+			return nil, err
+		}
+		policies[service] = policy
+	}
+
+	return policies, nil
+}
+
+// loadCallPolicy reads the settings with the given prefix, falling back to defaults
+func loadCallPolicy(prefix string, defaults CallPolicy) (CallPolicy, error) {
+	policy := defaults
+
+	durations := map[string]*time.Duration{
+		"TIMEOUT":         &policy.Timeout,
+		"INITIAL_BACKOFF": &policy.InitialBackoff,
+		"MAX_BACKOFF":     &policy.MaxBackoff,
+		"HEDGE_DELAY":     &policy.HedgeDelay,
+	}
+	for name, value := range durations {
+		raw := getEnvOrDefault(prefix+name, "")
+		if raw == "" {
+			continue
+		}
+		d, err := time.ParseDuration(raw)
+		if err != nil || d < 0 {
+			return CallPolicy{}, fmt.Errorf("%s%s must be a non-negative duration such as 500ms", prefix, name)
+		}
+		*value = d
+	}
+
+	if raw := getEnvOrDefault(prefix+"MAX_ATTEMPTS", ""); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			return CallPolicy{}, fmt.Errorf("%sMAX_ATTEMPTS must be a positive number", prefix)
+		}
+		policy.MaxAttempts = n
+	}
+
+	return policy, nil
+}
+
+// clientInterceptors returns the interceptor chain for the connection to a microservice.
+// Spans and metrics are taken outermost, so that they cover retries and calls failed fast by the
+// breaker. The breaker sits outside the call policy, so that a call counts once however many
+// attempts it took, and outside the health tracker, so that calls failed fast by an open
+// breaker don't hide the error the service last returned.
+func clientInterceptors(service string, policy CallPolicy, breakers *Breakers, health *BackendHealth, metrics *Metrics) []grpc.UnaryClientInterceptor {
+	return []grpc.UnaryClientInterceptor{
+		backendErrorInterceptor(service),
+		tracingInterceptor(service),
+		metrics.interceptor(service),
+		breakers.interceptor(service),
+		health.interceptor(service),
+		callPolicyInterceptor(policy),
+	}
+}
+
+// callPolicyInterceptor applies a call policy to every unary call
+func callPolicyInterceptor(policy CallPolicy) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		attempt := func(ctx context.Context, reply any) error {
+			if policy.Timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, policy.Timeout)
+				defer cancel()
+			}
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		if policy.MaxAttempts <= 1 || !isIdempotentRead(method) {
+			return attempt(ctx, reply)
+		}
+
+		if policy.HedgeDelay > 0 {
+			return hedgeCall(ctx, policy, reply, attempt)
+		}
+
+		return retryCall(ctx, policy, reply, attempt)
+	}
+}
+
+// retryCall repeats a call that failed with Unavailable, waiting a jittered exponential
+// backoff between attempts
+func retryCall(ctx context.Context, policy CallPolicy, reply any, attempt func(context.Context, any) error) error {
+	var err error
+	for n := range policy.MaxAttempts {
+		if n > 0 {
+			if waitErr := sleepContext(ctx, backoff(policy, n)); waitErr != nil {
+				return err
+			}
+		}
+
+		if err = attempt(ctx, reply); !isRetryable(err) {
+			return err
+		}
+	}
+
+	return err
+}
+
+// hedgeCall sends another attempt each time the previous ones have not answered within the
+// hedge delay, or as soon as one fails with Unavailable. The first other result wins and
+// the attempts still in flight are cancelled.
+func hedgeCall(ctx context.Context, policy CallPolicy, reply any, attempt func(context.Context, any) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	msg, ok := reply.(proto.Message)
+	if !ok {
+		return attempt(ctx, reply)
+	}
+
+	type result struct {
+		reply proto.Message
+		err   error
+	}
+	results := make(chan result, policy.MaxAttempts)
+
+	launch := func() {
+		// Concurrent attempts must not share the reply message
+		r := msg.ProtoReflect().New().Interface()
+		go func() {
+			results <- result{reply: r, err: attempt(ctx, r)}
+		}()
+	}
+
+	launch()
+	sent, pending := 1, 1
+
+	hedge := time.NewTimer(policy.HedgeDelay)
+	defer hedge.Stop()
+
+	var lastErr error
+	for pending > 0 {
+		select {
+		case res := <-results:
+			pending--
+			if !isRetryable(res.err) {
+				if res.err == nil {
+					proto.Merge(msg, res.reply)
+				}
+				return res.err
+			}
+			lastErr = res.err
+			if sent < policy.MaxAttempts {
+				launch()
+				sent++
+				pending++
+				hedge.Reset(policy.HedgeDelay)
+			}
+		case <-hedge.C:
+			if sent < policy.MaxAttempts {
+				launch()
+				sent++
+				pending++
+				hedge.Reset(policy.HedgeDelay)
+			}
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		}
+	}
+
+	return lastErr
+}
+
+// isIdempotentRead reports whether a gRPC method only reads data and may safely be repeated.
+// The microservices follow the convention of naming their lookups Get*.
+func isIdempotentRead(fullMethod string) bool {
+	name := fullMethod[strings.LastIndex(fullMethod, "/")+1:]
+	return strings.HasPrefix(name, "Get")
+}
+
+// isRetryable reports whether a failed attempt may be repeated
+func isRetryable(err error) bool {
+	return err != nil && status.Code(err) == codes.Unavailable
+}
+
+// backoff returns the delay before the nth retry, using full jitter
+func backoff(policy CallPolicy, n int) time.Duration {
+	limit := policy.InitialBackoff << (n - 1)
+	if limit <= 0 || limit > policy.MaxBackoff {
+		limit = policy.MaxBackoff
+	}
+	if limit <= 0 {
+		return 0
+	}
+
+	return rand.N(limit)
+}
+
+// sleepContext waits for d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
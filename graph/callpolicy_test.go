@@ -0,0 +1,206 @@
+package graph
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	testReadMethod  = "/test.TestService/GetThing"
+	testWriteMethod = "/test.TestService/UpdateThing"
+)
+
+// flakyServer is a fake microservice answering each call with the behaviour of its attempt
+type flakyServer struct {
+	calls atomic.Int64
+	// attempt returns the latency and error of the nth call, counting from 1
+	attempt func(n int64) (time.Duration, error)
+}
+
+// dial starts the server and connects to it through the call policy
+func (s *flakyServer) dial(t *testing.T, policy CallPolicy) *grpc.ClientConn {
+	t.Helper()
+
+	addr := startFakeServer(t, func(ctx context.Context, _ string, _ *structpb.Struct) (*structpb.Struct, error) {
+		n := s.calls.Add(1)
+		latency, err := s.attempt(n)
+
+		select {
+		case <-time.After(latency):
+		case <-ctx.Done():
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return structpb.NewStruct(map[string]any{"attempt": n})
+	})
+
+	return dialFake(t, addr, grpc.WithUnaryInterceptor(callPolicyInterceptor(policy)))
+}
+
+// invoke calls method and returns the attempt that answered
+func invoke(ctx context.Context, conn *grpc.ClientConn, method string) (int64, error) {
+	out := &structpb.Struct{}
+	if err := conn.Invoke(ctx, method, &structpb.Struct{}, out); err != nil {
+		return 0, err
+	}
+	return int64(out.Fields["attempt"].GetNumberValue()), nil
+}
+
+// testCallPolicy retries quickly so that the tests don't wait on backoff
+var testCallPolicy = CallPolicy{
+	Timeout:        time.Second,
+	MaxAttempts:    3,
+	InitialBackoff: time.Millisecond,
+	MaxBackoff:     5 * time.Millisecond,
+}
+
+func TestCallPolicyRetriesUnavailableReads(t *testing.T) {
+	s := &flakyServer{attempt: func(n int64) (time.Duration, error) {
+		if n < 3 {
+			return 0, status.Error(codes.Unavailable, "restarting")
+		}
+		return 0, nil
+	}}
+	conn := s.dial(t, testCallPolicy)
+
+	attempt, err := invoke(context.Background(), conn, testReadMethod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempt != 3 {
+		t.Errorf("answered by attempt %d, want 3", attempt)
+	}
+}
+
+func TestCallPolicyGivesUpAfterMaxAttempts(t *testing.T) {
+	s := &flakyServer{attempt: func(int64) (time.Duration, error) {
+		return 0, status.Error(codes.Unavailable, "down")
+	}}
+	conn := s.dial(t, testCallPolicy)
+
+	if _, err := invoke(context.Background(), conn, testReadMethod); status.Code(err) != codes.Unavailable {
+		t.Errorf("got error %v, want Unavailable", err)
+	}
+	if n := s.calls.Load(); n != 3 {
+		t.Errorf("got %d calls, want 3", n)
+	}
+}
+
+func TestCallPolicyDoesNotRetry(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		err    error
+	}{
+		{"writes", testWriteMethod, status.Error(codes.Unavailable, "down")},
+		{"reads failing with other codes", testReadMethod, status.Error(codes.NotFound, "no such thing")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &flakyServer{attempt: func(int64) (time.Duration, error) { return 0, tt.err }}
+			conn := s.dial(t, testCallPolicy)
+
+			if _, err := invoke(context.Background(), conn, tt.method); status.Code(err) != status.Code(tt.err) {
+				t.Errorf("got error %v, want %v", err, tt.err)
+			}
+			if n := s.calls.Load(); n != 1 {
+				t.Errorf("got %d calls, want 1", n)
+			}
+		})
+	}
+}
+
+func TestCallPolicyDeadline(t *testing.T) {
+	s := &flakyServer{attempt: func(int64) (time.Duration, error) { return time.Minute, nil }}
+	policy := testCallPolicy
+	policy.Timeout = 50 * time.Millisecond
+	conn := s.dial(t, policy)
+
+	start := time.Now()
+	_, err := invoke(context.Background(), conn, testReadMethod)
+	if status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("got error %v, want DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("call took %v with a 50ms timeout", elapsed)
+	}
+
+	// A call timing out is not retried, the service is slow rather than unreachable
+	if n := s.calls.Load(); n != 1 {
+		t.Errorf("got %d calls, want 1", n)
+	}
+}
+
+func TestCallPolicyHedgesSlowReads(t *testing.T) {
+	s := &flakyServer{attempt: func(n int64) (time.Duration, error) {
+		if n == 1 {
+			return time.Minute, nil
+		}
+		return 0, nil
+	}}
+	policy := testCallPolicy
+	policy.HedgeDelay = 20 * time.Millisecond
+	conn := s.dial(t, policy)
+
+	start := time.Now()
+	attempt, err := invoke(context.Background(), conn, testReadMethod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempt != 2 {
+		t.Errorf("answered by attempt %d, want the hedged attempt 2", attempt)
+	}
+	if elapsed := time.Since(start); elapsed > policy.Timeout {
+		t.Errorf("hedged call took %v, as long as the slow attempt", elapsed)
+	}
+}
+
+func TestCallPolicyHedgingDoesNotDuplicateWrites(t *testing.T) {
+	s := &flakyServer{attempt: func(int64) (time.Duration, error) { return 100 * time.Millisecond, nil }}
+	policy := testCallPolicy
+	policy.HedgeDelay = 10 * time.Millisecond
+	conn := s.dial(t, policy)
+
+	if _, err := invoke(context.Background(), conn, testWriteMethod); err != nil {
+		t.Fatal(err)
+	}
+	if n := s.calls.Load(); n != 1 {
+		t.Errorf("got %d calls, want 1", n)
+	}
+}
+
+func TestLoadCallPolicies(t *testing.T) {
+	t.Setenv("GRPC_TIMEOUT", "2s")
+	t.Setenv("GRPC_MAX_ATTEMPTS", "4")
+	t.Setenv("GRADES_GRPC_TIMEOUT", "500ms")
+	t.Setenv("GRADES_GRPC_HEDGE_DELAY", "100ms")
+
+	policies, err := LoadCallPolicies()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	grades, students := policies["grades"], policies["students"]
+	if grades.Timeout != 500*time.Millisecond || grades.HedgeDelay != 100*time.Millisecond || grades.MaxAttempts != 4 {
+		t.Errorf("got grades policy %+v", grades)
+	}
+	if students.Timeout != 2*time.Second || students.HedgeDelay != 0 || students.MaxAttempts != 4 {
+		t.Errorf("got students policy %+v", students)
+	}
+
+	t.Setenv("STAFF_GRPC_MAX_ATTEMPTS", "0")
+	if _, err := LoadCallPolicies(); err == nil {
+		t.Error("accepted STAFF_GRPC_MAX_ATTEMPTS=0")
+	}
+}
@@ -0,0 +1,206 @@
+// Package dataloader implements a small generic loader that batches, dedupes and
+// caches lookups by key. A Loader is meant to live for a single request: keys
+// requested within the same tick are collected into one batch, each key is
+// fetched at most once, and the result is reused for the rest of the request.
+package dataloader
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultWait is how long a loader collects keys before dispatching a batch
+	DefaultWait = 2 * time.Millisecond
+
+	// DefaultMaxBatch is the number of keys after which a batch is dispatched immediately
+	DefaultMaxBatch = 100
+)
+
+// BatchFunc fetches the values of a batch of keys. It must return one value and
+// one error (which may be nil) per key, in the same order as the keys.
+type BatchFunc[K comparable, V any] func(ctx context.Context, keys []K) ([]V, []error)
+
+// Option configures a Loader
+type Option func(*options)
+
+type options struct {
+	wait     time.Duration
+	maxBatch int
+}
+
+// WithWait sets how long the loader waits for more keys before dispatching a batch
+func WithWait(wait time.Duration) Option {
+	return func(o *options) {
+		o.wait = wait
+	}
+}
+
+// WithMaxBatch sets the maximum number of keys per batch (0 means unlimited)
+func WithMaxBatch(maxBatch int) Option {
+	return func(o *options) {
+		o.maxBatch = maxBatch
+	}
+}
+
+// Loader batches and caches lookups of values of type V by keys of type K
+type Loader[K comparable, V any] struct {
+	fetch    BatchFunc[K, V]
+	wait     time.Duration
+	maxBatch int
+
+	mu    sync.Mutex
+	cache map[K]*thunk[V]
+	batch *batch[K, V]
+}
+
+// thunk holds the eventual result of loading a single key
+type thunk[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+// batch holds the keys collected since the last dispatch. ctx carries the values of the
+// request that started the batch but not its cancellation, as the keys of the batch may have
+// been requested by callers that are still waiting.
+type batch[K comparable, V any] struct {
+	ctx    context.Context
+	keys   []K
+	thunks []*thunk[V]
+}
+
+// New creates a Loader that uses fetch to load batches of keys
+func New[K comparable, V any](fetch BatchFunc[K, V], opts ...Option) *Loader[K, V] {
+	o := options{
+		wait:     DefaultWait,
+		maxBatch: DefaultMaxBatch,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return &Loader[K, V]{
+		fetch:    fetch,
+		wait:     o.wait,
+		maxBatch: o.maxBatch,
+		cache:    make(map[K]*thunk[V]),
+	}
+}
+
+// Load returns the value for key, batching the lookup with other keys requested
+// in the same tick and reusing any previous result for the same key.
+func (l *Loader[K, V]) Load(ctx context.Context, key K) (V, error) {
+	return l.await(ctx, l.enqueue(ctx, key))
+}
+
+// LoadAll returns the values for keys in order. The returned error slice has one
+// entry per key, nil where the key was loaded successfully.
+func (l *Loader[K, V]) LoadAll(ctx context.Context, keys []K) ([]V, []error) {
+	thunks := make([]*thunk[V], len(keys))
+	for i, key := range keys {
+		thunks[i] = l.enqueue(ctx, key)
+	}
+
+	values := make([]V, len(keys))
+	errs := make([]error, len(keys))
+	for i, t := range thunks {
+		values[i], errs[i] = l.await(ctx, t)
+	}
+
+	return values, errs
+}
+
+// Prime stores value for key unless the key was already requested
+func (l *Loader[K, V]) Prime(key K, value V) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.cache[key]; ok {
+		return
+	}
+
+	t := &thunk[V]{done: make(chan struct{}), value: value}
+	close(t.done)
+	l.cache[key] = t
+}
+
+// enqueue returns the thunk for key, adding the key to the pending batch if it
+// has not been requested before
+func (l *Loader[K, V]) enqueue(ctx context.Context, key K) *thunk[V] {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if t, ok := l.cache[key]; ok {
+		return t
+	}
+
+	t := &thunk[V]{done: make(chan struct{})}
+	l.cache[key] = t
+
+	// Start a new batch and schedule its dispatch
+	if l.batch == nil {
+		b := &batch[K, V]{ctx: context.WithoutCancel(ctx)}
+		l.batch = b
+		time.AfterFunc(l.wait, func() { l.dispatch(b) })
+	}
+
+	l.batch.keys = append(l.batch.keys, key)
+	l.batch.thunks = append(l.batch.thunks, t)
+
+	// Dispatch right away once the batch is full
+	if l.maxBatch > 0 && len(l.batch.keys) >= l.maxBatch {
+		b := l.batch
+		l.batch = nil
+		go l.run(b)
+	}
+
+	return t
+}
+
+// dispatch runs b unless it has already been dispatched because it was full
+func (l *Loader[K, V]) dispatch(b *batch[K, V]) {
+	l.mu.Lock()
+	if l.batch != b {
+		l.mu.Unlock()
+		return
+	}
+	l.batch = nil
+	l.mu.Unlock()
+
+	l.run(b)
+}
+
+// run fetches the keys of b and resolves their thunks
+func (l *Loader[K, V]) run(b *batch[K, V]) {
+	values, errs := l.fetch(b.ctx, b.keys)
+
+	for i, t := range b.thunks {
+		switch {
+		case len(values) != len(b.keys):
+			t.err = fmt.Errorf("dataloader: batch function returned %d values for %d keys", len(values), len(b.keys))
+		case errs != nil && len(errs) != len(b.keys):
+			t.err = fmt.Errorf("dataloader: batch function returned %d errors for %d keys", len(errs), len(b.keys))
+		default:
+			t.value = values[i]
+			if errs != nil {
+				t.err = errs[i]
+			}
+		}
+		close(t.done)
+	}
+}
+
+// await blocks until t is resolved or ctx is done. A caller giving up leaves the batch
+// running for the other callers waiting on its keys.
+func (l *Loader[K, V]) await(ctx context.Context, t *thunk[V]) (V, error) {
+	select {
+	case <-t.done:
+		return t.value, t.err
+	case <-ctx.Done():
+		var zero V
+		return zero, ctx.Err()
+	}
+}
@@ -0,0 +1,151 @@
+package dataloader
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recorder is a batch function recording the batches it is called with. Values are the
+// keys in upper case.
+type recorder struct {
+	mu      sync.Mutex
+	batches [][]string
+}
+
+func (r *recorder) fetch(_ context.Context, keys []string) ([]string, []error) {
+	r.mu.Lock()
+	r.batches = append(r.batches, slices.Clone(keys))
+	r.mu.Unlock()
+
+	values := make([]string, len(keys))
+	for i, key := range keys {
+		values[i] = strings.ToUpper(key)
+	}
+	return values, nil
+}
+
+func TestLoaderDedupesKeys(t *testing.T) {
+	var r recorder
+	loader := New(r.fetch)
+
+	var wg sync.WaitGroup
+	for range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			values, errs := loader.LoadAll(context.Background(), []string{"a", "b", "a"})
+			if !slices.Equal(values, []string{"A", "B", "A"}) || errors.Join(errs...) != nil {
+				t.Errorf("got %v, %v", values, errs)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Loaded keys are served from the cache
+	if value, err := loader.Load(context.Background(), "b"); value != "B" || err != nil {
+		t.Errorf("got %q, %v", value, err)
+	}
+
+	if len(r.batches) != 1 || !slices.Equal(r.batches[0], []string{"a", "b"}) {
+		t.Errorf("got batches %v, want a single batch of a and b", r.batches)
+	}
+}
+
+func TestLoaderSplitsBatchesAtMaxBatch(t *testing.T) {
+	var r recorder
+	loader := New(r.fetch, WithMaxBatch(2))
+
+	values, errs := loader.LoadAll(context.Background(), []string{"a", "b", "c", "d", "e"})
+	if !slices.Equal(values, []string{"A", "B", "C", "D", "E"}) || errors.Join(errs...) != nil {
+		t.Fatalf("got %v, %v", values, errs)
+	}
+
+	sizes := make([]int, len(r.batches))
+	for i, batch := range r.batches {
+		sizes[i] = len(batch)
+	}
+	slices.Sort(sizes)
+	if !slices.Equal(sizes, []int{1, 2, 2}) {
+		t.Errorf("got batches %v, want two batches of 2 keys and one of 1", r.batches)
+	}
+}
+
+func TestLoaderReportsPerKeyErrors(t *testing.T) {
+	errMissing := errors.New("missing")
+	loader := New(func(_ context.Context, keys []string) ([]string, []error) {
+		values := make([]string, len(keys))
+		errs := make([]error, len(keys))
+		for i, key := range keys {
+			if key == "b" {
+				errs[i] = errMissing
+			} else {
+				values[i] = strings.ToUpper(key)
+			}
+		}
+		return values, errs
+	})
+
+	values, errs := loader.LoadAll(context.Background(), []string{"a", "b"})
+	if values[0] != "A" || errs[0] != nil || !errors.Is(errs[1], errMissing) {
+		t.Errorf("got %v, %v, want A and the error of b", values, errs)
+	}
+
+	short := New(func(context.Context, []string) ([]string, []error) { return nil, nil })
+	if _, err := short.Load(context.Background(), "a"); err == nil {
+		t.Error("got no error from a batch function returning no values")
+	}
+}
+
+type requestKey struct{}
+
+func TestCancelledCallerLeavesBatchRunning(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	var batchErr error
+	var requestID any
+	loader := New(func(ctx context.Context, keys []string) ([]string, []error) {
+		close(started)
+		<-release
+		batchErr, requestID = ctx.Err(), ctx.Value(requestKey{})
+		return slices.Clone(keys), nil
+	})
+
+	// The first caller starts the batch, then gives up while it runs
+	first, cancel := context.WithCancel(context.WithValue(context.Background(), requestKey{}, "r1"))
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := loader.Load(first, "a")
+		firstErr <- err
+	}()
+	<-started
+
+	second := make(chan string, 1)
+	go func() {
+		value, err := loader.Load(context.Background(), "a")
+		if err != nil {
+			t.Errorf("second caller: %v", err)
+		}
+		second <- value
+	}()
+
+	cancel()
+	if err := <-firstErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("first caller: got %v, want context.Canceled", err)
+	}
+
+	close(release)
+	if value := <-second; value != "a" {
+		t.Errorf("second caller: got %q, want a", value)
+	}
+	if batchErr != nil {
+		t.Errorf("batch ran with a cancelled context: %v", batchErr)
+	}
+	if requestID != "r1" {
+		t.Errorf("batch context carries %v, want the values of the first caller", requestID)
+	}
+}
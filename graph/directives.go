@@ -0,0 +1,91 @@
+package graph
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/BetterGR/api-gateway/graph/model"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+const (
+	// ErrCodeUnauthenticated is reported when a field requires a verified token and none was presented
+	ErrCodeUnauthenticated = "UNAUTHENTICATED"
+
+	// ErrCodeForbidden is reported when the caller lacks the permissions a field requires
+	ErrCodeForbidden = "FORBIDDEN"
+)
+
+// NewDirectives returns the implementations of the schema directives
+func NewDirectives() DirectiveRoot {
+	return DirectiveRoot{
+		Auth:    authDirective,
+		HasRole: hasRoleDirective,
+		NodeId:  nodeIDDirective,
+	}
+}
+
+// authDirective implements @auth: the field resolves only for callers with a verified token
+func authDirective(ctx context.Context, obj any, next graphql.Resolver) (any, error) {
+	if GetClaims(ctx) == nil {
+		return nil, unauthenticatedError(ctx)
+	}
+
+	return next(ctx)
+}
+
+// hasRoleDirective implements @hasRole: the field resolves only for callers holding one of roles
+func hasRoleDirective(ctx context.Context, obj any, next graphql.Resolver, roles []model.Role) (any, error) {
+	claims := GetClaims(ctx)
+	if claims == nil {
+		return nil, unauthenticatedError(ctx)
+	}
+
+	for _, role := range roles {
+		if claims.HasRole(role) {
+			return next(ctx)
+		}
+	}
+
+	requiredRoles := make([]string, len(roles))
+	for i, role := range roles {
+		requiredRoles[i] = role.String()
+	}
+
+	return nil, &gqlerror.Error{
+		Message: "you are not allowed to access this field",
+		Path:    graphql.GetPath(ctx),
+		Extensions: map[string]any{
+			"code":          ErrCodeForbidden,
+			"requiredRoles": requiredRoles,
+		},
+	}
+}
+
+// unauthenticatedError is returned when a protected field is resolved without a verified token
+func unauthenticatedError(ctx context.Context) error {
+	return &gqlerror.Error{
+		Message: "authentication required",
+		Path:    graphql.GetPath(ctx),
+		Extensions: map[string]any{
+			"code": ErrCodeUnauthenticated,
+		},
+	}
+}
+
+// HasRole reports whether the claims grant role, either as a realm role or as a role of the
+// gateway's own client. Roles of the other clients of the realm are ignored, they may share
+// names with the gateway's roles without meaning the same. Keycloak role names are matched
+// case-insensitively.
+func (c *Claims) HasRole(role model.Role) bool {
+	roles := c.RealmAccess.Roles
+	if c.clientID != "" {
+		roles = append(slices.Clip(roles), c.ResourceAccess[c.clientID].Roles...)
+	}
+
+	return slices.ContainsFunc(roles, func(r string) bool {
+		return strings.EqualFold(r, role.String())
+	})
+}
@@ -0,0 +1,211 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"maps"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Error codes reported in the extensions.code field of GraphQL errors, in addition to
+// ErrCodeUnauthenticated and ErrCodeForbidden
+const (
+	ErrCodeNotFound           = "NOT_FOUND"
+	ErrCodeBadUserInput       = "BAD_USER_INPUT"
+	ErrCodeConflict           = "CONFLICT"
+	ErrCodeRateLimited        = "RATE_LIMITED"
+	ErrCodeServiceUnavailable = "SERVICE_UNAVAILABLE"
+	ErrCodeTimeout            = "TIMEOUT"
+	ErrCodeCancelled          = "CANCELLED"
+	ErrCodeNotImplemented     = "NOT_IMPLEMENTED"
+	ErrCodeInternal           = "INTERNAL_SERVER_ERROR"
+)
+
+// grpcErrorCodes maps gRPC status codes to the codes exposed to GraphQL clients
+var grpcErrorCodes = map[codes.Code]string{
+	codes.NotFound:           ErrCodeNotFound,
+	codes.PermissionDenied:   ErrCodeForbidden,
+	codes.Unauthenticated:    ErrCodeUnauthenticated,
+	codes.InvalidArgument:    ErrCodeBadUserInput,
+	codes.OutOfRange:         ErrCodeBadUserInput,
+	codes.FailedPrecondition: ErrCodeBadUserInput,
+	codes.AlreadyExists:      ErrCodeConflict,
+	codes.Aborted:            ErrCodeConflict,
+	codes.ResourceExhausted:  ErrCodeRateLimited,
+	codes.Unavailable:        ErrCodeServiceUnavailable,
+	codes.DeadlineExceeded:   ErrCodeTimeout,
+	codes.Canceled:           ErrCodeCancelled,
+	codes.Unimplemented:      ErrCodeNotImplemented,
+}
+
+// publicErrorMessages replace the backend's error messages in production mode
+var publicErrorMessages = map[string]string{
+	ErrCodeNotFound:           "the requested resource was not found",
+	ErrCodeForbidden:          "you are not allowed to perform this operation",
+	ErrCodeUnauthenticated:    "authentication required",
+	ErrCodeBadUserInput:       "the request is invalid",
+	ErrCodeConflict:           "the request conflicts with the current state of the resource",
+	ErrCodeRateLimited:        "too many requests",
+	ErrCodeServiceUnavailable: "the service is temporarily unavailable",
+	ErrCodeTimeout:            "the service took too long to respond",
+	ErrCodeCancelled:          "the request was cancelled",
+	ErrCodeNotImplemented:     "this operation is not supported",
+	ErrCodeInternal:           "internal server error",
+}
+
+// BackendError annotates an error returned by a microservice with the name of that service
+type BackendError struct {
+	Service string
+	Method  string
+	Err     error
+}
+
+// Error returns the message of the underlying error
+func (e *BackendError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *BackendError) Unwrap() error {
+	return e.Err
+}
+
+// GRPCStatus returns the gRPC status of the underlying error, so status.FromError and
+// status.Code keep working on annotated errors
+func (e *BackendError) GRPCStatus() *status.Status {
+	return status.Convert(e.Err)
+}
+
+// backendErrorInterceptor tags the errors returned by a microservice with the service's name
+func backendErrorInterceptor(service string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if err := invoker(ctx, method, req, reply, cc, opts...); err != nil {
+			return &BackendError{Service: service, Method: method, Err: err}
+		}
+		return nil
+	}
+}
+
+// NewErrorPresenter returns the presenter that turns resolver errors into GraphQL errors.
+// gRPC errors get an extensions.code derived from their status code and the name of the
+// microservice that failed. Any other error without a code is unexpected and reported as
+// INTERNAL_SERVER_ERROR, and logged. In production mode the backend's message, and that of
+// unexpected errors, is replaced by a generic one so internal details don't leak to clients.
+func NewErrorPresenter(production bool) graphql.ErrorPresenterFunc {
+	return func(ctx context.Context, err error) *gqlerror.Error {
+		gqlErr := graphql.DefaultErrorPresenter(ctx, err)
+
+		// Errors raised by the gateway itself (directives, policies) already carry a code
+		if _, ok := gqlErr.Extensions["code"]; ok {
+			return gqlErr
+		}
+
+		st, ok := grpcStatus(err)
+		if !ok {
+			return presentInternalError(ctx, gqlErr, err, production)
+		}
+
+		code, ok := grpcErrorCodes[st.Code()]
+		if !ok {
+			code = ErrCodeInternal
+		}
+
+		if gqlErr.Extensions == nil {
+			gqlErr.Extensions = map[string]any{}
+		}
+		gqlErr.Extensions["code"] = code
+
+		var backendErr *BackendError
+		if errors.As(err, &backendErr) {
+			gqlErr.Extensions["service"] = backendErr.Service
+		}
+
+		if production {
+			gqlErr.Message = publicErrorMessages[code]
+		} else {
+			gqlErr.Message = st.Message()
+			gqlErr.Extensions["grpcCode"] = st.Code().String()
+		}
+
+		return gqlErr
+	}
+}
+
+// presentInternalError reports an error that neither the gateway nor a microservice gave a
+// code to. The original error is logged, as clients only see a generic message in production.
+func presentInternalError(ctx context.Context, gqlErr *gqlerror.Error, err error, production bool) *gqlerror.Error {
+	LoggerFromContext(ctx).Error("unexpected error", "path", gqlErr.Path.String(), "error", err)
+
+	// Leave the original error as it was, resolvers may still hold on to it
+	presented := *gqlErr
+	presented.Extensions = maps.Clone(gqlErr.Extensions)
+	if presented.Extensions == nil {
+		presented.Extensions = map[string]any{}
+	}
+	presented.Extensions["code"] = ErrCodeInternal
+
+	if production {
+		presented.Message = publicErrorMessages[ErrCodeInternal]
+	}
+
+	return &presented
+}
+
+// grpcStatus finds the gRPC status in err's chain. Unlike status.FromError, the status
+// keeps the backend's own message when err has been wrapped by the resolvers.
+func grpcStatus(err error) (*status.Status, bool) {
+	var withStatus interface{ GRPCStatus() *status.Status }
+	if !errors.As(err, &withStatus) {
+		return nil, false
+	}
+
+	return withStatus.GRPCStatus(), true
+}
+
+// badUserInputError is returned when the arguments of a field are invalid
+func badUserInputError(ctx context.Context, message string) error {
+	return &gqlerror.Error{
+		Message:    message,
+		Path:       graphql.GetPath(ctx),
+		Extensions: map[string]any{"code": ErrCodeBadUserInput},
+	}
+}
+
+// notFoundError is returned when the gateway finds no object matching the arguments of a field
+func notFoundError(ctx context.Context, message string) error {
+	return &gqlerror.Error{
+		Message:    message,
+		Path:       graphql.GetPath(ctx),
+		Extensions: map[string]any{"code": ErrCodeNotFound},
+	}
+}
+
+// isNotFound reports whether err tells that an object does not exist, whether it was
+// returned by a microservice or by the gateway
+func isNotFound(err error) bool {
+	var gqlErr *gqlerror.Error
+	if errors.As(err, &gqlErr) && gqlErr.Extensions["code"] == ErrCodeNotFound {
+		return true
+	}
+
+	st, ok := grpcStatus(err)
+	return ok && st.Code() == codes.NotFound
+}
+
+// errorOnPath reports err on path, keeping the message and extensions of errors that are
+// already GraphQL errors
+func errorOnPath(path ast.Path, err error) *gqlerror.Error {
+	if gqlErr, ok := err.(*gqlerror.Error); ok {
+		withPath := *gqlErr
+		withPath.Path = path
+		return &withPath
+	}
+
+	return gqlerror.WrapPath(path, err)
+}
@@ -0,0 +1,99 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestErrorPresenter(t *testing.T) {
+	backendErr := &BackendError{Service: "grades", Err: status.Error(codes.NotFound, "grade 7 not found in table grades")}
+
+	tests := []struct {
+		name        string
+		production  bool
+		err         error
+		wantCode    string
+		wantMessage string
+		wantService string
+		wantLogged  bool
+	}{
+		{
+			name:        "backend error",
+			err:         backendErr,
+			wantCode:    ErrCodeNotFound,
+			wantMessage: "grade 7 not found in table grades",
+			wantService: "grades",
+		},
+		{
+			name:        "backend error in production",
+			production:  true,
+			err:         fmt.Errorf("fetching grade: %w", backendErr),
+			wantCode:    ErrCodeNotFound,
+			wantMessage: publicErrorMessages[ErrCodeNotFound],
+			wantService: "grades",
+		},
+		{
+			name:        "gateway error keeps its code and message",
+			production:  true,
+			err:         &gqlerror.Error{Message: "filter.minValue is invalid", Extensions: map[string]any{"code": ErrCodeBadUserInput}},
+			wantCode:    ErrCodeBadUserInput,
+			wantMessage: "filter.minValue is invalid",
+		},
+		{
+			name:        "uncoded error",
+			err:         errors.New("dial tcp 10.0.0.7:5432: connection refused"),
+			wantCode:    ErrCodeInternal,
+			wantMessage: "dial tcp 10.0.0.7:5432: connection refused",
+			wantLogged:  true,
+		},
+		{
+			name:        "uncoded error in production",
+			production:  true,
+			err:         errors.New("dial tcp 10.0.0.7:5432: connection refused"),
+			wantCode:    ErrCodeInternal,
+			wantMessage: publicErrorMessages[ErrCodeInternal],
+			wantLogged:  true,
+		},
+		{
+			name:        "uncoded GraphQL error in production",
+			production:  true,
+			err:         gqlerror.Errorf("internal system error"),
+			wantCode:    ErrCodeInternal,
+			wantMessage: publicErrorMessages[ErrCodeInternal],
+			wantLogged:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			ctx := context.WithValue(context.Background(), loggerKey, slog.New(slog.NewTextHandler(&logs, nil)))
+			ctx = graphql.WithResponseContext(ctx, graphql.DefaultErrorPresenter, graphql.DefaultRecover)
+
+			gqlErr := NewErrorPresenter(tt.production)(ctx, tt.err)
+
+			if code := gqlErr.Extensions["code"]; code != tt.wantCode {
+				t.Errorf("got code %v, want %s", code, tt.wantCode)
+			}
+			if gqlErr.Message != tt.wantMessage {
+				t.Errorf("got message %q, want %q", gqlErr.Message, tt.wantMessage)
+			}
+			if service, _ := gqlErr.Extensions["service"].(string); service != tt.wantService {
+				t.Errorf("got service %q, want %q", service, tt.wantService)
+			}
+			if logged := strings.Contains(logs.String(), tt.err.Error()); logged != tt.wantLogged {
+				t.Errorf("original error logged: %v, want %v", logged, tt.wantLogged)
+			}
+		})
+	}
+}
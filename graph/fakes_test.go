@@ -0,0 +1,347 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync/atomic"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/BetterGR/api-gateway/graph/model"
+	"github.com/BetterGR/api-gateway/graph/pubsub"
+	coursespb "github.com/BetterGR/courses-microservice/protos"
+	gradespb "github.com/BetterGR/grades-microservice/protos"
+	staffpb "github.com/BetterGR/staff-microservice/protos"
+	studentspb "github.com/BetterGR/students-microservice/protos"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// fakeSchool is the state behind the fake microservices used by the tests:
+//
+//   - c1 is taught by t1 and taken by s1 and s2
+//   - c2 is taught by t2 and taken by s1, s2 and s3
+//   - s1 and s2 have a grade in c1, s2 also has one in c2
+//   - c1 and c2 each have an announcement, a1 and a2
+type fakeSchool struct {
+	enrollments   map[string][]string
+	assignments   map[string][]string
+	grades        []*gradespb.SingleGrade
+	announcements map[string][]*coursespb.Announcement
+
+	// unavailableStudents are the students whose lookups fail
+	unavailableStudents map[string]bool
+
+	// calls counts the calls made to the fake microservices
+	calls atomic.Int64
+}
+
+func newFakeSchool() *fakeSchool {
+	return &fakeSchool{
+		enrollments: map[string][]string{
+			"c1": {"s1", "s2"},
+			"c2": {"s1", "s2", "s3"},
+		},
+		assignments: map[string][]string{
+			"c1": {"t1"},
+			"c2": {"t2"},
+		},
+		grades: []*gradespb.SingleGrade{
+			{GradeID: "g1", StudentID: "s1", CourseID: "c1", Semester: "2025-spring", GradeType: "exam", ItemID: "final", GradeValue: "80"},
+			{GradeID: "g2", StudentID: "s2", CourseID: "c1", Semester: "2025-spring", GradeType: "exam", ItemID: "final", GradeValue: "90"},
+			{GradeID: "g3", StudentID: "s2", CourseID: "c2", Semester: "2025-spring", GradeType: "exam", ItemID: "final", GradeValue: "70"},
+		},
+		announcements: map[string][]*coursespb.Announcement{
+			"c1": {{AnnouncementID: "a1", AnnouncementTitle: "Welcome to c1"}},
+			"c2": {{AnnouncementID: "a2", AnnouncementTitle: "Welcome to c2"}},
+		},
+	}
+}
+
+// coursesOf returns the courses in which members holds id
+func coursesOf(members map[string][]string, id string) []string {
+	var courseIDs []string
+	for courseID, ids := range members {
+		if slices.Contains(ids, id) {
+			courseIDs = append(courseIDs, courseID)
+		}
+	}
+	slices.Sort(courseIDs)
+	return courseIDs
+}
+
+// resolver returns a resolver backed by the fake microservices
+func (f *fakeSchool) resolver() *Resolver {
+	return &Resolver{
+		StudentsClient: fakeStudents{fakeSchool: f},
+		StaffClient:    fakeStaff{fakeSchool: f},
+		CoursesClient:  fakeCourses{fakeSchool: f},
+		GradesClient:   fakeGrades{fakeSchool: f},
+		PubSub:         pubsub.NewMemoryBus(),
+	}
+}
+
+type fakeCourses struct {
+	*fakeSchool
+	coursespb.CoursesServiceClient
+}
+
+func (f fakeCourses) GetCourse(_ context.Context, in *coursespb.GetCourseRequest, _ ...grpc.CallOption) (*coursespb.GetCourseResponse, error) {
+	f.calls.Add(1)
+	if _, ok := f.enrollments[in.CourseID]; !ok {
+		return nil, status.Error(codes.NotFound, "course not found")
+	}
+	return &coursespb.GetCourseResponse{Course: &coursespb.Course{CourseID: in.CourseID, CourseName: "Course " + in.CourseID}}, nil
+}
+
+func (f fakeCourses) GetCourseStudents(_ context.Context, in *coursespb.GetCourseStudentsRequest, _ ...grpc.CallOption) (*coursespb.GetCourseStudentsResponse, error) {
+	f.calls.Add(1)
+	return &coursespb.GetCourseStudentsResponse{StudentsIDs: f.enrollments[in.CourseID]}, nil
+}
+
+func (f fakeCourses) GetCourseStaff(_ context.Context, in *coursespb.GetCourseStaffRequest, _ ...grpc.CallOption) (*coursespb.GetCourseStaffResponse, error) {
+	f.calls.Add(1)
+	return &coursespb.GetCourseStaffResponse{StaffIDs: f.assignments[in.CourseID]}, nil
+}
+
+func (f fakeCourses) GetStudentCourses(_ context.Context, in *coursespb.GetStudentCoursesRequest, _ ...grpc.CallOption) (*coursespb.GetStudentCoursesResponse, error) {
+	f.calls.Add(1)
+	return &coursespb.GetStudentCoursesResponse{CoursesIDs: coursesOf(f.enrollments, in.StudentID)}, nil
+}
+
+func (f fakeCourses) GetStaffCourses(_ context.Context, in *coursespb.GetStaffCoursesRequest, _ ...grpc.CallOption) (*coursespb.GetStaffCoursesResponse, error) {
+	f.calls.Add(1)
+	return &coursespb.GetStaffCoursesResponse{CoursesIDs: coursesOf(f.assignments, in.StaffID)}, nil
+}
+
+func (f fakeCourses) GetCourseAnnouncements(_ context.Context, in *coursespb.GetCourseAnnouncementsRequest, _ ...grpc.CallOption) (*coursespb.GetCourseAnnouncementsResponse, error) {
+	f.calls.Add(1)
+	return &coursespb.GetCourseAnnouncementsResponse{Announcements: f.announcements[in.CourseID]}, nil
+}
+
+type fakeStudents struct {
+	*fakeSchool
+	studentspb.StudentsServiceClient
+}
+
+func (f fakeStudents) GetStudent(_ context.Context, in *studentspb.GetStudentRequest, _ ...grpc.CallOption) (*studentspb.GetStudentResponse, error) {
+	f.calls.Add(1)
+	if f.unavailableStudents[in.StudentID] {
+		return nil, status.Error(codes.Unavailable, "students database unreachable")
+	}
+	return &studentspb.GetStudentResponse{Student: &studentspb.Student{
+		StudentID:   in.StudentID,
+		FirstName:   "Student",
+		LastName:    in.StudentID,
+		Email:       in.StudentID + "@example.com",
+		PhoneNumber: "555-" + in.StudentID,
+	}}, nil
+}
+
+type fakeStaff struct {
+	*fakeSchool
+	staffpb.StaffServiceClient
+}
+
+func (f fakeStaff) GetStaffMember(_ context.Context, in *staffpb.GetStaffMemberRequest, _ ...grpc.CallOption) (*staffpb.GetStaffMemberResponse, error) {
+	f.calls.Add(1)
+	return &staffpb.GetStaffMemberResponse{StaffMember: &staffpb.StaffMember{
+		StaffID:   in.StaffID,
+		FirstName: "Staff",
+		LastName:  in.StaffID,
+		Email:     in.StaffID + "@example.com",
+	}}, nil
+}
+
+type fakeGrades struct {
+	*fakeSchool
+	gradespb.GradesServiceClient
+}
+
+func (f fakeGrades) GetCourseGrades(_ context.Context, in *gradespb.GetCourseGradesRequest, _ ...grpc.CallOption) (*gradespb.GetCourseGradesResponse, error) {
+	f.calls.Add(1)
+	return &gradespb.GetCourseGradesResponse{Grades: f.findGrades(func(g *gradespb.SingleGrade) bool {
+		return g.CourseID == in.CourseID && g.Semester == in.Semester
+	})}, nil
+}
+
+func (f fakeGrades) GetStudentCourseGrades(_ context.Context, in *gradespb.GetStudentCourseGradesRequest, _ ...grpc.CallOption) (*gradespb.GetStudentCourseGradesResponse, error) {
+	f.calls.Add(1)
+	return &gradespb.GetStudentCourseGradesResponse{Grades: f.findGrades(func(g *gradespb.SingleGrade) bool {
+		return g.StudentID == in.StudentID && g.CourseID == in.CourseID && g.Semester == in.Semester
+	})}, nil
+}
+
+func (f fakeGrades) GetStudentSemesterGrades(_ context.Context, in *gradespb.GetStudentSemesterGradesRequest, _ ...grpc.CallOption) (*gradespb.GetStudentSemesterGradesResponse, error) {
+	f.calls.Add(1)
+	return &gradespb.GetStudentSemesterGradesResponse{Grades: f.findGrades(func(g *gradespb.SingleGrade) bool {
+		return g.StudentID == in.StudentID && (in.Semester == "" || g.Semester == in.Semester)
+	})}, nil
+}
+
+func (f fakeGrades) UpdateSingleGrade(_ context.Context, in *gradespb.UpdateSingleGradeRequest, _ ...grpc.CallOption) (*gradespb.UpdateSingleGradeResponse, error) {
+	f.calls.Add(1)
+	for _, g := range f.grades {
+		if g.GradeID == in.Grade.GradeID {
+			g.GradeValue, g.Comments = in.Grade.GradeValue, in.Grade.Comments
+			return &gradespb.UpdateSingleGradeResponse{Grade: g}, nil
+		}
+	}
+	return nil, status.Error(codes.NotFound, "grade not found")
+}
+
+func (f fakeGrades) RemoveSingleGrade(_ context.Context, in *gradespb.RemoveSingleGradeRequest, _ ...grpc.CallOption) (*gradespb.RemoveSingleGradeResponse, error) {
+	f.calls.Add(1)
+	for i, g := range f.grades {
+		if g.GradeID == in.GradeID && g.CourseID == in.CourseID && g.Semester == in.Semester &&
+			g.StudentID == in.StudentID && g.GradeType == in.GradeType && g.ItemID == in.ItemID {
+			f.grades = slices.Delete(f.grades, i, i+1)
+			return &gradespb.RemoveSingleGradeResponse{}, nil
+		}
+	}
+	return nil, status.Error(codes.NotFound, "grade not found")
+}
+
+func (f fakeGrades) findGrades(match func(*gradespb.SingleGrade) bool) []*gradespb.SingleGrade {
+	var grades []*gradespb.SingleGrade
+	for _, g := range f.grades {
+		if match(g) {
+			grades = append(grades, g)
+		}
+	}
+	return grades
+}
+
+// withClaims authenticates the requests passed to next as the given user
+func withClaims(subject string, roles []string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(contextWithClaims(r.Context(), subject, roles...)))
+	})
+}
+
+// contextWithClaims returns ctx authenticated as the given user
+func contextWithClaims(ctx context.Context, subject string, roles ...string) context.Context {
+	claims := &Claims{RealmAccess: RoleSet{Roles: roles}}
+	claims.Subject = subject
+	return context.WithValue(ctx, ClaimsKey, claims)
+}
+
+// newTestServer returns a GraphQL server over the resolver, configured like the gateway's
+func newTestServer(r *Resolver) *handler.Server {
+	srv := handler.New(NewExecutableSchema(Config{Resolvers: r, Directives: NewDirectives()}))
+	srv.AddTransport(transport.POST{})
+	srv.SetErrorPresenter(NewErrorPresenter(false))
+	srv.Use(NewLoaderExtension(r))
+	return srv
+}
+
+// graphQLResponse is the decoded response to a GraphQL request
+type graphQLResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string         `json:"message"`
+		Path       []any          `json:"path"`
+		Extensions map[string]any `json:"extensions"`
+	} `json:"errors"`
+}
+
+// errorCodes returns the codes of the errors of the response
+func (res graphQLResponse) errorCodes() []string {
+	codes := make([]string, len(res.Errors))
+	for i, err := range res.Errors {
+		codes[i], _ = err.Extensions["code"].(string)
+	}
+	return codes
+}
+
+// errorCode returns the code of a GraphQL error returned by a resolver, "" for other errors
+func errorCode(err error) string {
+	var gqlErr *gqlerror.Error
+	if !errors.As(err, &gqlErr) {
+		return ""
+	}
+	code, _ := gqlErr.Extensions["code"].(string)
+	return code
+}
+
+// execute sends query to h and decodes the response
+func execute(t *testing.T, h http.Handler, query string, variables map[string]any) graphQLResponse {
+	t.Helper()
+
+	body, err := json.Marshal(map[string]any{"query": query, "variables": variables})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/query", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	var res graphQLResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatalf("decoding response %q: %v", rec.Body.String(), err)
+	}
+	return res
+}
+
+// executeAs sends query to a server over r, authenticated as the given user
+func executeAs(t *testing.T, r *Resolver, subject string, role model.Role, query string) graphQLResponse {
+	t.Helper()
+	return execute(t, withClaims(subject, []string{role.String()}, newTestServer(r)), query, nil)
+}
+
+// fakeHandler answers the calls made to a fake gRPC server
+type fakeHandler func(ctx context.Context, method string, in *structpb.Struct) (*structpb.Struct, error)
+
+// startFakeServer serves handle over gRPC on a local port and returns its address. Requests and
+// responses are Structs, whatever the method called.
+func startFakeServer(t *testing.T, handle fakeHandler) string {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := grpc.NewServer(grpc.UnknownServiceHandler(func(_ any, stream grpc.ServerStream) error {
+		method, _ := grpc.MethodFromServerStream(stream)
+		in := &structpb.Struct{}
+		if err := stream.RecvMsg(in); err != nil {
+			return err
+		}
+		out, err := handle(stream.Context(), method, in)
+		if err != nil {
+			return err
+		}
+		return stream.SendMsg(out)
+	}))
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	return lis.Addr().String()
+}
+
+// dialFake connects to a fake gRPC server
+func dialFake(t *testing.T, addr string, opts ...grpc.DialOption) *grpc.ClientConn {
+	t.Helper()
+
+	conn, err := grpc.NewClient(addr, append([]grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
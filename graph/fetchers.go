@@ -0,0 +1,390 @@
+package graph
+
+import (
+	"context"
+	"slices"
+
+	"github.com/99designs/gqlgen/graphql"
+
+	"github.com/BetterGR/api-gateway/graph/model"
+	coursespb "github.com/BetterGR/courses-microservice/protos"
+	gradespb "github.com/BetterGR/grades-microservice/protos"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// The helpers in this file are shared between the root query resolvers and the
+// field resolvers of the object types, so that e.g. courseStudents(courseId) and
+// course(id) { students } go through the same code path. All lookups go through
+// the per-response loaders so repeated IDs in nested queries are fetched once.
+//
+// Lists of records looked up one by one degrade gracefully: a record that fails to
+// load is returned as nil and its error is reported on the path of that list item,
+// so the rest of the list still renders.
+
+// fetchStudent returns a single student if the caller may see their profile
+func (r *Resolver) fetchStudent(ctx context.Context, studentID string) (*model.Student, error) {
+	// Students may only read their own profile
+	if err := r.authorizeStudentRecord(ctx, studentID); err != nil {
+		return nil, err
+	}
+
+	student, err := r.loaders(ctx).Students.Load(ctx, studentID)
+	if err != nil {
+		return nil, err
+	}
+
+	return convertStudentToGraphQL(student), nil
+}
+
+// fetchStaffMember returns a single staff member if the caller may see their profile
+func (r *Resolver) fetchStaffMember(ctx context.Context, staffID string) (*model.Staff, error) {
+	// Staff profiles are visible to the members of their courses
+	if err := r.authorizeStaffRecord(ctx, staffID); err != nil {
+		return nil, err
+	}
+
+	staffMember, err := r.loaders(ctx).Staff.Load(ctx, staffID)
+	if err != nil {
+		return nil, err
+	}
+
+	return convertStaffToGraphQL(staffMember), nil
+}
+
+// fetchCourse returns a single course
+func (r *Resolver) fetchCourse(ctx context.Context, courseID string) (*model.Course, error) {
+	course, err := r.loaders(ctx).Courses.Load(ctx, courseID)
+	if err != nil {
+		return nil, err
+	}
+
+	return convertCourseToGraphQL(course), nil
+}
+
+// fetchCourseStudents returns the students enrolled in a course that the caller may see
+func (r *Resolver) fetchCourseStudents(ctx context.Context, courseID string) ([]*model.Student, error) {
+	// Get the IDs of the students enrolled in the course
+	studentIDs, err := r.fetchCourseStudentIDs(ctx, courseID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the details for each student from the students microservice
+	students, errs := r.fetchStudents(ctx, studentIDs)
+	addItemErrors(ctx, errs)
+
+	return students, nil
+}
+
+// fetchCourseStudentsConnection returns a page of the students enrolled in a course that the
+// caller may see. Only the students on the page are looked up.
+func (r *Resolver) fetchCourseStudentsConnection(ctx context.Context, courseID string, args pageArgs) (*model.StudentConnection, error) {
+	// Get the IDs of the students enrolled in the course
+	studentIDs, err := r.fetchCourseStudentIDs(ctx, courseID)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := paginate(ctx, len(studentIDs), args)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the details for each student on the page from the students microservice
+	students, errs := r.fetchStudents(ctx, studentIDs[p.start:p.end])
+	addEdgeErrors(ctx, errs)
+
+	return &model.StudentConnection{
+		Edges: pageEdges(p, students, func(cursor string, node *model.Student) *model.StudentEdge {
+			return &model.StudentEdge{Cursor: cursor, Node: node}
+		}),
+		PageInfo:   p.info,
+		TotalCount: int32(len(studentIDs)),
+	}, nil
+}
+
+// fetchCourseStudentIDs returns the IDs of the students enrolled in a course that the caller
+// may see: all of them for admins and the staff of the course, only themselves for students
+func (r *Resolver) fetchCourseStudentIDs(ctx context.Context, courseID string) ([]string, error) {
+	ownerID, err := r.courseRecordsScope(ctx, courseID)
+	if err != nil {
+		return nil, err
+	}
+
+	studentIDs, err := r.loaders(ctx).CourseStudentIDs.Load(ctx, courseID)
+	if err != nil {
+		return nil, err
+	}
+
+	if ownerID != "" {
+		return slices.DeleteFunc(slices.Clone(studentIDs), func(id string) bool { return id != ownerID }), nil
+	}
+
+	return studentIDs, nil
+}
+
+// fetchStudents returns the details of each of the given students, nil for those that failed
+// to load along with their error
+func (r *Resolver) fetchStudents(ctx context.Context, studentIDs []string) ([]*model.Student, []error) {
+	studentsRes, errs := r.loaders(ctx).Students.LoadAll(ctx, studentIDs)
+
+	students := make([]*model.Student, len(studentsRes))
+	for i, s := range studentsRes {
+		if errs[i] == nil {
+			students[i] = convertStudentToGraphQL(s)
+		}
+	}
+
+	return students, errs
+}
+
+// fetchCourseStaff returns the staff members assigned to a course, if the caller is a member of it
+func (r *Resolver) fetchCourseStaff(ctx context.Context, courseID string) ([]*model.Staff, error) {
+	// The staff of a course is visible to everyone taking or teaching it
+	if _, err := r.courseRecordsScope(ctx, courseID); err != nil {
+		return nil, err
+	}
+
+	loaders := r.loaders(ctx)
+
+	// Get the IDs of the staff members assigned to the course
+	staffIDs, err := loaders.CourseStaffIDs.Load(ctx, courseID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the details for each staff member from the staff microservice
+	staffRes, errs := loaders.Staff.LoadAll(ctx, staffIDs)
+	addItemErrors(ctx, errs)
+
+	staffMembers := make([]*model.Staff, len(staffRes))
+	for i, s := range staffRes {
+		if errs[i] == nil {
+			staffMembers[i] = convertStaffToGraphQL(s)
+		}
+	}
+
+	return staffMembers, nil
+}
+
+// fetchSemesterCourses returns the courses given during a semester
+func (r *Resolver) fetchSemesterCourses(ctx context.Context, semester string) ([]*model.Course, error) {
+	// Create an authenticated context with the token
+	authCtx := r.CreateAuthContext(ctx)
+
+	// Get the token for the request
+	token := r.GetAuthTokenForRequest(ctx)
+
+	// Create a gRPC request to the courses microservice
+	req := &coursespb.GetSemesterCoursesRequest{
+		Semester: semester,
+		Token:    token,
+	}
+
+	// Call the courses microservice with the authenticated context
+	res, err := r.CoursesClient.GetSemesterCourses(authCtx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Convert the response to GraphQL model, priming the course loader so nested
+	// selections that refer back to these courses don't fetch them again
+	loaders := r.loaders(ctx)
+	courses := make([]*model.Course, len(res.Courses))
+	for i, c := range res.Courses {
+		loaders.Courses.Prime(c.CourseID, c)
+		courses[i] = convertCourseToGraphQL(c)
+	}
+
+	return courses, nil
+}
+
+// fetchCourseAnnouncements returns the announcements published in a course, if the caller is
+// a member of it
+func (r *Resolver) fetchCourseAnnouncements(ctx context.Context, courseID string) ([]*model.Announcement, error) {
+	// Announcements are visible to everyone taking or teaching the course
+	if _, err := r.courseRecordsScope(ctx, courseID); err != nil {
+		return nil, err
+	}
+
+	// Create an authenticated context with the token
+	authCtx := r.CreateAuthContext(ctx)
+
+	// Get the token for the request
+	token := r.GetAuthTokenForRequest(ctx)
+
+	// Create a gRPC request to get course announcements
+	req := &coursespb.GetCourseAnnouncementsRequest{
+		CourseID: courseID,
+		Token:    token,
+	}
+
+	// Call the courses microservice with the authenticated context
+	res, err := r.CoursesClient.GetCourseAnnouncements(authCtx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return convertAnnouncementsToGraphQL(courseID, res.Announcements), nil
+}
+
+// fetchAnnouncement returns the announcement identified by key, if the caller is a member of its course
+func (r *Resolver) fetchAnnouncement(ctx context.Context, key announcementKey) (*model.Announcement, error) {
+	// The courses microservice only lists the announcements of a course
+	announcements, err := r.fetchCourseAnnouncements(ctx, key.CourseID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, announcement := range announcements {
+		if announcement.ID == key.AnnouncementID {
+			return announcement, nil
+		}
+	}
+
+	return nil, notFoundError(ctx, "announcement not found")
+}
+
+// fetchCourseGrades returns the grades given in a course during a semester that the caller may see
+func (r *Resolver) fetchCourseGrades(ctx context.Context, courseID, semester string) ([]*model.Grade, error) {
+	// Students enrolled in the course only get their own grades
+	ownerID, err := r.courseRecordsScope(ctx, courseID)
+	if err != nil {
+		return nil, err
+	}
+
+	grades, err := r.loaders(ctx).CourseGrades.Load(ctx, courseSemesterKey{CourseID: courseID, Semester: semester})
+	if err != nil {
+		return nil, err
+	}
+
+	if ownerID != "" {
+		return filterGradesByStudent(convertGradesToGraphQL(grades), ownerID), nil
+	}
+
+	return convertGradesToGraphQL(grades), nil
+}
+
+// fetchGrade returns the grade identified by key if the caller may see it
+func (r *Resolver) fetchGrade(ctx context.Context, key gradeKey) (*model.Grade, error) {
+	// Check the caller may read this student's grades in the course
+	if err := r.authorizeStudentCourseRecord(ctx, key.StudentID, key.CourseID); err != nil {
+		return nil, err
+	}
+
+	// Create an authenticated context with the token
+	authCtx := r.CreateAuthContext(ctx)
+
+	// Get the token for the request
+	token := r.GetAuthTokenForRequest(ctx)
+
+	// Get the grades of the student in the course, as the grades microservice has no
+	// lookup of a single grade
+	req := &gradespb.GetStudentCourseGradesRequest{
+		StudentID: key.StudentID,
+		CourseID:  key.CourseID,
+		Semester:  key.Semester,
+		Token:     token,
+	}
+
+	// Call the grades microservice with the authenticated context
+	res, err := r.GradesClient.GetStudentCourseGrades(authCtx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, grade := range convertGradesToGraphQL(res.Grades) {
+		if grade.GradeType == key.GradeType && grade.ItemID == key.ItemID {
+			return grade, nil
+		}
+	}
+
+	return nil, notFoundError(ctx, "grade not found")
+}
+
+// fetchHomework returns a single homework assignment if the caller is a member of its course
+func (r *Resolver) fetchHomework(ctx context.Context, homeworkID string) (*model.Homework, error) {
+	homework, err := r.loaders(ctx).Homework.Load(ctx, homeworkID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Homework is visible to everyone taking or teaching the course
+	if _, err := r.courseRecordsScope(ctx, homework.CourseID); err != nil {
+		return nil, err
+	}
+
+	return homework, nil
+}
+
+// fetchCourseHomework returns the homework assigned in a course
+func (r *Resolver) fetchCourseHomework(ctx context.Context, courseID string) ([]*model.Homework, error) {
+	// Homework is visible to everyone taking or teaching the course
+	if _, err := r.courseRecordsScope(ctx, courseID); err != nil {
+		return nil, err
+	}
+
+	return r.loaders(ctx).CourseHomework.Load(ctx, courseID)
+}
+
+// fetchStudentCourses returns the courses a student is enrolled in
+func (r *Resolver) fetchStudentCourses(ctx context.Context, studentID string) ([]*model.Course, error) {
+	// A student's enrollments are part of their records
+	if err := r.authorizeStudentRecord(ctx, studentID); err != nil {
+		return nil, err
+	}
+
+	// Get the IDs of the courses the student is enrolled in
+	courseIDs, err := r.loaders(ctx).StudentCourseIDs.Load(ctx, studentID)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.fetchCourses(ctx, courseIDs)
+}
+
+// fetchStaffCourses returns the courses a staff member is assigned to
+func (r *Resolver) fetchStaffCourses(ctx context.Context, staffID string) ([]*model.Course, error) {
+	// A staff member's assignments are part of their profile
+	if err := r.authorizeStaffRecord(ctx, staffID); err != nil {
+		return nil, err
+	}
+
+	// Get the IDs of the courses the staff member is assigned to
+	courseIDs, err := r.loaders(ctx).StaffCourseIDs.Load(ctx, staffID)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.fetchCourses(ctx, courseIDs)
+}
+
+// fetchCourses returns the details of each of the given courses
+func (r *Resolver) fetchCourses(ctx context.Context, courseIDs []string) ([]*model.Course, error) {
+	coursesRes, errs := r.loaders(ctx).Courses.LoadAll(ctx, courseIDs)
+	addItemErrors(ctx, errs)
+
+	courses := make([]*model.Course, len(coursesRes))
+	for i, c := range coursesRes {
+		if errs[i] == nil {
+			courses[i] = convertCourseToGraphQL(c)
+		}
+	}
+
+	return courses, nil
+}
+
+// addItemErrors reports the failed lookups of a batch on the path of the list item they belong to
+func addItemErrors(ctx context.Context, errs []error) {
+	fieldPath := graphql.GetPath(ctx)
+
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+
+		path := make(ast.Path, len(fieldPath), len(fieldPath)+1)
+		copy(path, fieldPath)
+		graphql.AddError(ctx, errorOnPath(append(path, ast.PathIndex(i)), err))
+	}
+}
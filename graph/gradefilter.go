@@ -0,0 +1,196 @@
+package graph
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BetterGR/api-gateway/graph/model"
+)
+
+// The grades microservice has no filtering or sorting of its own, so the filter and orderBy
+// arguments of the grade list queries are evaluated in the gateway on the fetched grades.
+//
+// Grade values are free-form strings: "87.5" as well as "A+" or "pass". Value ranges only
+// match the values that parse as numbers, and ordering by value puts the numeric values
+// first, in numeric order, followed by the others in alphabetical order.
+
+// applyGradeQuery returns the grades matching filter, sorted by order. Grades are kept in
+// the order of the microservice when order is nil.
+func applyGradeQuery(ctx context.Context, grades []*model.Grade, filter *model.GradeFilter, order *model.GradeOrder) ([]*model.Grade, error) {
+	if filter != nil {
+		match, err := gradeMatcher(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+
+		filtered := make([]*model.Grade, 0, len(grades))
+		for _, g := range grades {
+			if match(g) {
+				filtered = append(filtered, g)
+			}
+		}
+		grades = filtered
+	}
+
+	if order != nil {
+		sortGrades(grades, order)
+	}
+
+	return grades, nil
+}
+
+// gradeMatcher returns a function reporting whether a grade matches filter
+func gradeMatcher(ctx context.Context, filter *model.GradeFilter) (func(*model.Grade) bool, error) {
+	if filter.MinValue != nil && filter.MaxValue != nil && *filter.MinValue > *filter.MaxValue {
+		return nil, badUserInputError(ctx, "filter.minValue cannot be greater than filter.maxValue")
+	}
+
+	gradedAfter, err := parseGradeFilterTime(ctx, "gradedAfter", filter.GradedAfter)
+	if err != nil {
+		return nil, err
+	}
+	gradedBefore, err := parseGradeFilterTime(ctx, "gradedBefore", filter.GradedBefore)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(g *model.Grade) bool {
+		if filter.GradeType != nil && g.GradeType != *filter.GradeType {
+			return false
+		}
+		if filter.ItemID != nil && g.ItemID != *filter.ItemID {
+			return false
+		}
+		if filter.StudentID != nil && g.StudentID != *filter.StudentID {
+			return false
+		}
+
+		// Non-numeric values never match a value range
+		if filter.MinValue != nil || filter.MaxValue != nil {
+			value, ok := numericGradeValue(g.GradeValue)
+			if !ok {
+				return false
+			}
+			if filter.MinValue != nil && value < *filter.MinValue {
+				return false
+			}
+			if filter.MaxValue != nil && value > *filter.MaxValue {
+				return false
+			}
+		}
+
+		// Grades without a valid gradedAt never match a time range
+		if gradedAfter != nil || gradedBefore != nil {
+			gradedAt, ok := gradeTime(g.GradedAt)
+			if !ok {
+				return false
+			}
+			if gradedAfter != nil && gradedAt.Before(*gradedAfter) {
+				return false
+			}
+			if gradedBefore != nil && gradedAt.After(*gradedBefore) {
+				return false
+			}
+		}
+
+		return true
+	}, nil
+}
+
+// parseGradeFilterTime parses the RFC 3339 time of a filter field, nil when it is not set
+func parseGradeFilterTime(ctx context.Context, field string, value *string) (*time.Time, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, *value)
+	if err != nil {
+		return nil, badUserInputError(ctx, fmt.Sprintf("filter.%s must be an RFC 3339 time, got %q", field, *value))
+	}
+
+	return &t, nil
+}
+
+// sortGrades sorts grades in place. Grades that compare equal are ordered by ID, so that
+// the order is the same from one request to the next and pages of a connection don't overlap.
+func sortGrades(grades []*model.Grade, order *model.GradeOrder) {
+	var compare func(a, b *model.Grade) int
+	switch order.Field {
+	case model.GradeOrderFieldValue:
+		compare = func(a, b *model.Grade) int { return compareGradeValues(a.GradeValue, b.GradeValue) }
+	case model.GradeOrderFieldGradedAt:
+		compare = func(a, b *model.Grade) int { return compareGradeTimes(a.GradedAt, b.GradedAt) }
+	case model.GradeOrderFieldStudentID:
+		compare = func(a, b *model.Grade) int { return strings.Compare(a.StudentID, b.StudentID) }
+	default:
+		return
+	}
+
+	descending := order.Direction == model.OrderDirectionDesc
+	slices.SortStableFunc(grades, func(a, b *model.Grade) int {
+		c := compare(a, b)
+		if c == 0 {
+			c = strings.Compare(a.ID, b.ID)
+		}
+		if descending {
+			return -c
+		}
+		return c
+	})
+}
+
+// compareGradeValues orders numeric values numerically before the other values, which are
+// ordered alphabetically
+func compareGradeValues(a, b string) int {
+	x, aNumeric := numericGradeValue(a)
+	y, bNumeric := numericGradeValue(b)
+
+	switch {
+	case aNumeric && bNumeric:
+		return cmp.Compare(x, y)
+	case aNumeric:
+		return -1
+	case bNumeric:
+		return 1
+	default:
+		return strings.Compare(strings.TrimSpace(a), strings.TrimSpace(b))
+	}
+}
+
+// compareGradeTimes orders times chronologically before the values that are not valid times
+func compareGradeTimes(a, b string) int {
+	x, aValid := gradeTime(a)
+	y, bValid := gradeTime(b)
+
+	switch {
+	case aValid && bValid:
+		return x.Compare(y)
+	case aValid:
+		return -1
+	case bValid:
+		return 1
+	default:
+		return strings.Compare(a, b)
+	}
+}
+
+// numericGradeValue parses a grade value as a finite number
+func numericGradeValue(value string) (float64, bool) {
+	n, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || math.IsNaN(n) || math.IsInf(n, 0) {
+		return 0, false
+	}
+	return n, true
+}
+
+// gradeTime parses the RFC 3339 time a grade was given at
+func gradeTime(value string) (time.Time, bool) {
+	t, err := time.Parse(time.RFC3339, value)
+	return t, err == nil
+}
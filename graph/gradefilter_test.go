@@ -0,0 +1,187 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/BetterGR/api-gateway/graph/model"
+	gradespb "github.com/BetterGR/grades-microservice/protos"
+)
+
+// testGrades returns grades with the given values, identified by their position
+func testGrades(values ...string) []*model.Grade {
+	grades := make([]*model.Grade, len(values))
+	for i, value := range values {
+		grades[i] = &model.Grade{ID: string(rune('a' + i)), GradeValue: value}
+	}
+	return grades
+}
+
+// gradeValues returns the values of grades, in order
+func gradeValues(grades []*model.Grade) []string {
+	values := make([]string, len(grades))
+	for i, g := range grades {
+		values[i] = g.GradeValue
+	}
+	return values
+}
+
+// ptr returns a pointer to v, for the optional fields of inputs
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestSortGradesByValue(t *testing.T) {
+	tests := []struct {
+		name      string
+		direction model.OrderDirection
+		values    []string
+		want      []string
+	}{
+		{
+			"numeric values in numeric order",
+			model.OrderDirectionAsc,
+			[]string{"9", "100", "87.5", " 42 ", "-1"},
+			[]string{"-1", "9", " 42 ", "87.5", "100"},
+		},
+		{
+			"numeric values before the others",
+			model.OrderDirectionAsc,
+			[]string{"pass", "90", "A+", "B", "75"},
+			[]string{"75", "90", "A+", "B", "pass"},
+		},
+		{
+			"non-finite values are not numeric",
+			model.OrderDirectionAsc,
+			[]string{"NaN", "Inf", "3"},
+			[]string{"3", "Inf", "NaN"},
+		},
+		{
+			"descending reverses the whole order",
+			model.OrderDirectionDesc,
+			[]string{"pass", "90", "A+", "75"},
+			[]string{"pass", "A+", "90", "75"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			grades := testGrades(tt.values...)
+			sortGrades(grades, &model.GradeOrder{Field: model.GradeOrderFieldValue, Direction: tt.direction})
+
+			if got := gradeValues(grades); !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSortGradesBreaksTiesByID(t *testing.T) {
+	grades := []*model.Grade{
+		{ID: "c", GradeValue: "90"},
+		{ID: "a", GradeValue: "90.0"},
+		{ID: "b", GradeValue: "80"},
+	}
+	sortGrades(grades, &model.GradeOrder{Field: model.GradeOrderFieldValue, Direction: model.OrderDirectionAsc})
+
+	ids := []string{grades[0].ID, grades[1].ID, grades[2].ID}
+	if !slices.Equal(ids, []string{"b", "a", "c"}) {
+		t.Errorf("got %v, want equal values ordered by ID", ids)
+	}
+}
+
+func TestFilterGradesByValue(t *testing.T) {
+	grades := testGrades("55", "A+", "70", "pass", "85.5", "100")
+
+	tests := []struct {
+		name   string
+		filter *model.GradeFilter
+		want   []string
+	}{
+		{"minimum", &model.GradeFilter{MinValue: ptr(70.0)}, []string{"70", "85.5", "100"}},
+		{"maximum", &model.GradeFilter{MaxValue: ptr(70.0)}, []string{"55", "70"}},
+		{"range", &model.GradeFilter{MinValue: ptr(60.0), MaxValue: ptr(90.0)}, []string{"70", "85.5"}},
+		{"no range keeps non-numeric values", &model.GradeFilter{}, []string{"55", "A+", "70", "pass", "85.5", "100"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := applyGradeQuery(context.Background(), slices.Clone(grades), tt.filter, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if values := gradeValues(got); !slices.Equal(values, tt.want) {
+				t.Errorf("got %q, want %q", values, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterGradesByTime(t *testing.T) {
+	grades := []*model.Grade{
+		{ID: "a", GradedAt: "2025-01-10T10:00:00Z"},
+		{ID: "b", GradedAt: "2025-02-10T10:00:00Z"},
+		{ID: "c", GradedAt: "not a time"},
+	}
+
+	got, err := applyGradeQuery(context.Background(), grades, &model.GradeFilter{GradedAfter: ptr("2025-02-01T00:00:00Z")}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].ID != "b" {
+		t.Errorf("got %v, want only the grade given after the time", got)
+	}
+}
+
+func TestGradeQueryRejectsInvalidFilters(t *testing.T) {
+	for name, filter := range map[string]*model.GradeFilter{
+		"inverted range": {MinValue: ptr(90.0), MaxValue: ptr(10.0)},
+		"invalid time":   {GradedBefore: ptr("yesterday")},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := applyGradeQuery(context.Background(), testGrades("50"), filter, nil); err == nil {
+				t.Error("filter accepted")
+			}
+		})
+	}
+}
+
+func TestCourseGradesFilterAndOrder(t *testing.T) {
+	school := newFakeSchool()
+	school.grades = append(school.grades,
+		&gradespb.SingleGrade{GradeID: "g4", StudentID: "s3", CourseID: "c1", Semester: "2025-spring", GradeType: "exam", ItemID: "final", GradeValue: "incomplete"},
+		&gradespb.SingleGrade{GradeID: "g5", StudentID: "s4", CourseID: "c1", Semester: "2025-spring", GradeType: "homework", ItemID: "hw1", GradeValue: "100"},
+	)
+	r := school.resolver()
+
+	res := executeAs(t, r, "t1", model.RoleStaff, `{
+		courseGrades(
+			courseId: "c1",
+			semester: "2025-spring",
+			filter: {gradeType: "exam"},
+			orderBy: {field: VALUE, direction: DESC}
+		) { gradeValue }
+	}`)
+	if len(res.Errors) > 0 {
+		t.Fatalf("unexpected errors %v", res.Errors)
+	}
+
+	var data struct {
+		CourseGrades []*model.Grade `json:"courseGrades"`
+	}
+	if err := json.Unmarshal(res.Data, &data); err != nil {
+		t.Fatal(err)
+	}
+	if got := gradeValues(data.CourseGrades); !slices.Equal(got, []string{"incomplete", "90", "80"}) {
+		t.Errorf("got %q, want the exam grades with the non-numeric value first", got)
+	}
+
+	res = executeAs(t, r, "t1", model.RoleStaff, `{
+		courseGrades(courseId: "c1", semester: "2025-spring", filter: {minValue: 90, maxValue: 10}) { id }
+	}`)
+	if codes := res.errorCodes(); !slices.Equal(codes, []string{ErrCodeBadUserInput}) {
+		t.Errorf("inverted range: got error codes %v, want BAD_USER_INPUT", codes)
+	}
+}
@@ -0,0 +1,204 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// probeTimeout bounds how long a readiness probe waits for a microservice
+const probeTimeout = 2 * time.Second
+
+// BackendHealth tracks the connections to the microservices and the last error each of them
+// returned, and probes them for the readiness and status endpoints
+type BackendHealth struct {
+	services []string
+	optional map[string]bool
+
+	mu        sync.Mutex
+	endpoints map[string]string
+	conns     map[string]*grpc.ClientConn
+	// probeConns are dialled without interceptors, so that probes are neither failed fast nor
+	// counted by the breakers, retried by the call policy or measured in the call metrics
+	probeConns map[string]*grpc.ClientConn
+	lastErrs   map[string]backendError
+}
+
+// backendError is the last error returned by a microservice
+type backendError struct {
+	message string
+	at      time.Time
+}
+
+// BackendStatus describes a microservice as reported by the status endpoint
+type BackendStatus struct {
+	Service  string `json:"service"`
+	Endpoint string `json:"endpoint"`
+	// State is the connectivity state of the gRPC connection
+	State string `json:"state"`
+	// Ready reports whether the service answered the probe
+	Ready bool `json:"ready"`
+	// Optional reports that the gateway is ready without the service
+	Optional    bool       `json:"optional,omitempty"`
+	LastError   string     `json:"lastError,omitempty"`
+	LastErrorAt *time.Time `json:"lastErrorAt,omitempty"`
+}
+
+// NewBackendHealth creates the health tracker for the required and optional services. Only
+// the required services gate readiness, the optional ones are only reported.
+func NewBackendHealth(required, optional []string) *BackendHealth {
+	services := append(slices.Clone(required), optional...)
+
+	h := &BackendHealth{
+		services:   services,
+		optional:   make(map[string]bool, len(optional)),
+		endpoints:  make(map[string]string, len(services)),
+		conns:      make(map[string]*grpc.ClientConn, len(services)),
+		probeConns: make(map[string]*grpc.ClientConn, len(services)),
+		lastErrs:   make(map[string]backendError, len(services)),
+	}
+	for _, service := range optional {
+		h.optional[service] = true
+	}
+
+	return h
+}
+
+// register records the connection to a microservice and dials the connection used to probe
+// it with opts, which must not add the interceptors of conn
+func (h *BackendHealth) register(service, endpoint string, conn *grpc.ClientConn, opts ...grpc.DialOption) error {
+	probeConn, err := grpc.NewClient(endpoint, opts...)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.endpoints[service] = endpoint
+	h.conns[service] = conn
+	h.probeConns[service] = probeConn
+	return nil
+}
+
+// Close closes the connections used to probe the microservices
+func (h *BackendHealth) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, conn := range h.probeConns {
+		conn.Close()
+	}
+}
+
+// interceptor remembers the last error showing that a microservice is unhealthy. Requests the
+// service rejected, such as lookups of missing records, are not recorded.
+func (h *BackendHealth) interceptor(service string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		if isServiceFailure(err) {
+			h.mu.Lock()
+			h.lastErrs[service] = backendError{message: err.Error(), at: time.Now()}
+			h.mu.Unlock()
+		}
+		return err
+	}
+}
+
+// Statuses probes every microservice in parallel
+func (h *BackendHealth) Statuses(ctx context.Context) []BackendStatus {
+	statuses := make([]BackendStatus, len(h.services))
+
+	var wg sync.WaitGroup
+	for i, service := range h.services {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			statuses[i] = h.probe(ctx, service)
+		}()
+	}
+	wg.Wait()
+
+	return statuses
+}
+
+// probe checks a single microservice. Services implementing the gRPC health checking
+// protocol must report SERVING; services that don't are ready as long as they answer.
+func (h *BackendHealth) probe(ctx context.Context, service string) BackendStatus {
+	h.mu.Lock()
+	conn, probeConn := h.conns[service], h.probeConns[service]
+	st := BackendStatus{Service: service, Endpoint: h.endpoints[service], Optional: h.optional[service]}
+	h.mu.Unlock()
+
+	if conn == nil {
+		st.State = "NOT_CONFIGURED"
+		return st
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	res, err := healthpb.NewHealthClient(probeConn).Check(ctx, &healthpb.HealthCheckRequest{})
+	switch {
+	case err == nil:
+		st.Ready = res.GetStatus() == healthpb.HealthCheckResponse_SERVING
+	case status.Code(err) == codes.Unimplemented:
+		st.Ready = true
+	}
+	st.State = conn.GetState().String()
+
+	h.mu.Lock()
+	if lastErr, ok := h.lastErrs[service]; ok {
+		st.LastError = lastErr.message
+		st.LastErrorAt = &lastErr.at
+	}
+	h.mu.Unlock()
+
+	return st
+}
+
+// ReadinessHandler answers 200 when every required microservice is ready and 503 otherwise
+func (h *BackendHealth) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var unready []string
+		for _, st := range h.Statuses(r.Context()) {
+			if !st.Ready && !st.Optional {
+				unready = append(unready, st.Service)
+			}
+		}
+
+		if len(unready) > 0 {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]any{"status": "unavailable", "unready": unready})
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]any{"status": "ready"})
+	})
+}
+
+// ServeHTTP reports the endpoint, connectivity state and last error of every microservice as JSON
+func (h *BackendHealth) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{"backends": h.Statuses(r.Context())})
+}
+
+// LivenessHandler answers 200 as long as the process is able to serve requests
+func LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"status": "ok"})
+	})
+}
+
+// writeJSON writes body as a JSON response with the given status code
+func writeJSON(w http.ResponseWriter, code int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(body)
+}
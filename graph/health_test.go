@@ -0,0 +1,85 @@
+package graph
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestProbesBypassTheCallInterceptors(t *testing.T) {
+	addr := startFakeServer(t, func(context.Context, string, *structpb.Struct) (*structpb.Struct, error) {
+		return nil, status.Error(codes.Unimplemented, "no health service")
+	})
+
+	// The connection used by the resolvers fails every call, as it does while the breaker is open
+	var intercepted atomic.Int64
+	conn := dialFake(t, addr, grpc.WithUnaryInterceptor(
+		func(context.Context, string, any, any, *grpc.ClientConn, grpc.UnaryInvoker, ...grpc.CallOption) error {
+			intercepted.Add(1)
+			return status.Error(codes.Unavailable, "circuit breaker is open")
+		},
+	))
+
+	health := NewBackendHealth([]string{"students"}, nil)
+	if err := health.register("students", addr, conn, grpc.WithTransportCredentials(insecure.NewCredentials())); err != nil {
+		t.Fatal(err)
+	}
+	defer health.Close()
+
+	st := health.Statuses(context.Background())[0]
+	if !st.Ready {
+		t.Errorf("service answering the probe is not ready: %+v", st)
+	}
+	if n := intercepted.Load(); n != 0 {
+		t.Errorf("probe went through the call interceptors %d times", n)
+	}
+}
+
+func TestReadinessIgnoresOptionalServices(t *testing.T) {
+	addr := startFakeServer(t, func(context.Context, string, *structpb.Struct) (*structpb.Struct, error) {
+		return nil, status.Error(codes.Unimplemented, "no health service")
+	})
+
+	tests := []struct {
+		name     string
+		register []string
+		want     int
+	}{
+		{"optional service not configured", []string{"students"}, http.StatusOK},
+		{"optional service unreachable", []string{"students", homeworkService}, http.StatusOK},
+		{"required service not configured", nil, http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			health := NewBackendHealth([]string{"students"}, []string{homeworkService})
+			defer health.Close()
+
+			for _, service := range tt.register {
+				endpoint := addr
+				if service == homeworkService {
+					// Nothing listens on port 1
+					endpoint = "127.0.0.1:1"
+				}
+				conn := dialFake(t, endpoint)
+				if err := health.register(service, endpoint, conn, grpc.WithTransportCredentials(insecure.NewCredentials())); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			rec := httptest.NewRecorder()
+			health.ReadinessHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			if rec.Code != tt.want {
+				t.Errorf("got status %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package graph
+
+import (
+	"context"
+
+	"github.com/BetterGR/api-gateway/graph/blobstore"
+	"github.com/BetterGR/api-gateway/graph/model"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// homeworkService is the name the homework microservice is reported under in errors
+const homeworkService = "homework"
+
+// HomeworkClient is the gateway's view of the homework microservice. The microservice does
+// not publish generated gRPC stubs yet, so the gateway depends on this interface, implemented
+// over gRPC by NewHomeworkClient following a provisional contract, and tests inject fakes of it.
+// The context passed to every method carries the caller's token as gRPC metadata.
+type HomeworkClient interface {
+	GetHomework(ctx context.Context, homeworkID string) (*model.Homework, error)
+	GetCourseHomework(ctx context.Context, courseID string) ([]*model.Homework, error)
+	CreateHomework(ctx context.Context, input model.NewHomework) (*model.Homework, error)
+	SubmitHomework(ctx context.Context, submission HomeworkSubmission) (*model.Submission, error)
+	GetSubmission(ctx context.Context, submissionID string) (*model.Submission, error)
+	GetStudentSubmissions(ctx context.Context, studentID string) ([]*model.Submission, error)
+}
+
+// HomeworkSubmission is a submission to record in the homework microservice. The file has
+// already been stored by the gateway, only its reference and metadata are sent along.
+type HomeworkSubmission struct {
+	HomeworkID  string
+	StudentID   string
+	Filename    string
+	ContentType string
+	File        blobstore.Object
+}
+
+// homeworkClient returns the configured homework client, or an error when the resolver was
+// built without one, as it is when HOMEWORK_PORT is not set
+func (r *Resolver) homeworkClient() (HomeworkClient, error) {
+	if r.HomeworkClient == nil {
+		return nil, &gqlerror.Error{
+			Message: "the homework service is not configured",
+			Extensions: map[string]any{
+				"code":    ErrCodeNotImplemented,
+				"service": homeworkService,
+			},
+		}
+	}
+
+	return r.HomeworkClient, nil
+}
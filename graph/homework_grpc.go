@@ -0,0 +1,132 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/BetterGR/api-gateway/graph/model"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// homeworkMethodPrefix is the full name of the gRPC service of the homework microservice
+const homeworkMethodPrefix = "/homework.HomeworkService/"
+
+// grpcHomeworkClient calls the homework microservice over gRPC. Until the microservice
+// publishes typed stubs, it follows a provisional contract: its methods take and return a
+// google.protobuf.Struct holding the JSON form of the gateway's models, e.g. GetHomework takes
+// {"homeworkId": "1"} and returns {"homework": {...}}. The messages are still protobuf, so the calls go through the same
+// deadlines, retries, breaker and metrics as those to the other microservices.
+type grpcHomeworkClient struct {
+	conn grpc.ClientConnInterface
+}
+
+// NewHomeworkClient returns a client for the homework microservice reachable over conn
+func NewHomeworkClient(conn grpc.ClientConnInterface) HomeworkClient {
+	return &grpcHomeworkClient{conn: conn}
+}
+
+// homeworkSubmissionMessage is the submission sent to SubmitHomework
+type homeworkSubmissionMessage struct {
+	HomeworkID  string `json:"homeworkId"`
+	StudentID   string `json:"studentId"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"contentType"`
+	FileRef     string `json:"fileRef"`
+	Size        int64  `json:"size"`
+	Checksum    string `json:"checksum"`
+}
+
+func (c *grpcHomeworkClient) GetHomework(ctx context.Context, homeworkID string) (*model.Homework, error) {
+	var homework *model.Homework
+	err := c.call(ctx, "GetHomework", map[string]string{"homeworkId": homeworkID}, "homework", &homework)
+	return homework, err
+}
+
+func (c *grpcHomeworkClient) GetCourseHomework(ctx context.Context, courseID string) ([]*model.Homework, error) {
+	var homework []*model.Homework
+	err := c.call(ctx, "GetCourseHomework", map[string]string{"courseId": courseID}, "homework", &homework)
+	return homework, err
+}
+
+func (c *grpcHomeworkClient) CreateHomework(ctx context.Context, input model.NewHomework) (*model.Homework, error) {
+	var homework *model.Homework
+	err := c.call(ctx, "CreateHomework", input, "homework", &homework)
+	return homework, err
+}
+
+func (c *grpcHomeworkClient) SubmitHomework(ctx context.Context, submission HomeworkSubmission) (*model.Submission, error) {
+	req := homeworkSubmissionMessage{
+		HomeworkID:  submission.HomeworkID,
+		StudentID:   submission.StudentID,
+		Filename:    submission.Filename,
+		ContentType: submission.ContentType,
+		FileRef:     submission.File.Ref,
+		Size:        submission.File.Size,
+		Checksum:    submission.File.Checksum,
+	}
+
+	var recorded *model.Submission
+	err := c.call(ctx, "SubmitHomework", req, "submission", &recorded)
+	return recorded, err
+}
+
+func (c *grpcHomeworkClient) GetSubmission(ctx context.Context, submissionID string) (*model.Submission, error) {
+	var submission *model.Submission
+	err := c.call(ctx, "GetSubmission", map[string]string{"submissionId": submissionID}, "submission", &submission)
+	return submission, err
+}
+
+func (c *grpcHomeworkClient) GetStudentSubmissions(ctx context.Context, studentID string) ([]*model.Submission, error) {
+	var submissions []*model.Submission
+	err := c.call(ctx, "GetStudentSubmissions", map[string]string{"studentId": studentID}, "submissions", &submissions)
+	return submissions, err
+}
+
+// call invokes method with req encoded as a Struct, and decodes the field of the response
+// named field into result
+func (c *grpcHomeworkClient) call(ctx context.Context, method string, req any, field string, result any) error {
+	in, err := toStruct(req)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s request: %w", method, err)
+	}
+
+	out := &structpb.Struct{}
+	if err := c.conn.Invoke(ctx, homeworkMethodPrefix+method, in, out); err != nil {
+		return err
+	}
+
+	value, ok := out.Fields[field]
+	if !ok {
+		return status.Errorf(codes.Internal, "%s response has no %s", method, field)
+	}
+
+	raw, err := protojson.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", method, err)
+	}
+	if err := json.Unmarshal(raw, result); err != nil {
+		return status.Errorf(codes.Internal, "malformed %s in %s response: %v", field, method, err)
+	}
+
+	return nil
+}
+
+// toStruct returns the JSON form of v as a Struct
+func toStruct(v any) (*structpb.Struct, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := map[string]any{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+
+	return structpb.NewStruct(fields)
+}
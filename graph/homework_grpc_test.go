@@ -0,0 +1,127 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/BetterGR/api-gateway/graph/blobstore"
+	"github.com/BetterGR/api-gateway/graph/model"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestHomeworkClientDecodesResponses(t *testing.T) {
+	addr := startFakeServer(t, func(_ context.Context, method string, in *structpb.Struct) (*structpb.Struct, error) {
+		switch method {
+		case homeworkMethodPrefix + "GetHomework":
+			return structpb.NewStruct(map[string]any{
+				"homework": map[string]any{"id": in.Fields["homeworkId"].GetStringValue(), "courseId": "c1", "title": "Sets"},
+			})
+		case homeworkMethodPrefix + "GetStudentSubmissions":
+			return structpb.NewStruct(map[string]any{
+				"submissions": []any{map[string]any{"id": "sub1", "homeworkId": "h1", "studentId": in.Fields["studentId"].GetStringValue(), "size": 42}},
+			})
+		default:
+			return nil, status.Error(codes.Unimplemented, method)
+		}
+	})
+	client := NewHomeworkClient(dialFake(t, addr))
+
+	homework, err := client.GetHomework(context.Background(), "h1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *homework != (model.Homework{ID: "h1", CourseID: "c1", Title: "Sets"}) {
+		t.Errorf("got homework %+v", homework)
+	}
+
+	submissions, err := client.GetStudentSubmissions(context.Background(), "s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(submissions) != 1 || submissions[0].StudentID != "s1" || submissions[0].Size == nil || *submissions[0].Size != 42 {
+		t.Errorf("got submissions %+v", submissions)
+	}
+
+	if _, err := client.GetSubmission(context.Background(), "sub1"); status.Code(err) != codes.Unimplemented {
+		t.Errorf("got error %v, want the status returned by the service", err)
+	}
+}
+
+func TestHomeworkClientSendsSubmission(t *testing.T) {
+	var got *structpb.Struct
+	addr := startFakeServer(t, func(_ context.Context, _ string, in *structpb.Struct) (*structpb.Struct, error) {
+		got = in
+		return structpb.NewStruct(map[string]any{"submission": map[string]any{"id": "sub1"}})
+	})
+	client := NewHomeworkClient(dialFake(t, addr))
+
+	submission, err := client.SubmitHomework(context.Background(), HomeworkSubmission{
+		HomeworkID:  "h1",
+		StudentID:   "s1",
+		Filename:    "solution.pdf",
+		ContentType: "application/pdf",
+		File:        blobstore.Object{Ref: "h1/s1/solution.pdf", Size: 3, Checksum: "abc"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if submission.ID != "sub1" {
+		t.Errorf("got submission %+v", submission)
+	}
+
+	want := map[string]any{
+		"homeworkId":  "h1",
+		"studentId":   "s1",
+		"filename":    "solution.pdf",
+		"contentType": "application/pdf",
+		"fileRef":     "h1/s1/solution.pdf",
+		"size":        float64(3),
+		"checksum":    "abc",
+	}
+	for field, value := range want {
+		if v := got.Fields[field].AsInterface(); v != value {
+			t.Errorf("request field %s = %v, want %v", field, v, value)
+		}
+	}
+}
+
+func TestHomeworkClientGoesThroughInterceptors(t *testing.T) {
+	addr := startFakeServer(t, func(context.Context, string, *structpb.Struct) (*structpb.Struct, error) {
+		return nil, status.Error(codes.NotFound, "no such homework")
+	})
+
+	breakers := NewBreakers(backendServices, BreakerSettings{FailureThreshold: 5, OpenTimeout: time.Minute, HalfOpenRequests: 1}, slog.Default())
+	conn := dialFake(t, addr, grpc.WithChainUnaryInterceptor(
+		clientInterceptors(homeworkService, defaultCallPolicy, breakers, NewBackendHealth(requiredServices, optionalServices), NewMetrics(nil))...,
+	))
+
+	_, err := NewHomeworkClient(conn).GetHomework(context.Background(), "h1")
+	if !isNotFound(err) {
+		t.Errorf("got error %v, want NotFound", err)
+	}
+
+	var backendErr *BackendError
+	if !errors.As(err, &backendErr) || backendErr.Service != homeworkService {
+		t.Errorf("got error %v, want it reported for the homework service", err)
+	}
+}
+
+func TestHomeworkFieldsWithoutHomeworkService(t *testing.T) {
+	r := newFakeSchool().resolver()
+	r.HomeworkClient = nil
+
+	res := executeAs(t, r, "t1", model.RoleStaff, `{ homework(id: "h1") { title } }`)
+	if codes := res.errorCodes(); !slices.Equal(codes, []string{ErrCodeNotImplemented}) {
+		t.Fatalf("got error codes %v, want NOT_IMPLEMENTED", codes)
+	}
+	if msg := res.Errors[0].Message; msg != "the homework service is not configured" {
+		t.Errorf("got message %q", msg)
+	}
+}
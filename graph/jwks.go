@@ -0,0 +1,243 @@
+package graph
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// defaultJWKSRefreshInterval is how long fetched keys are trusted before they are refreshed
+	defaultJWKSRefreshInterval = time.Hour
+
+	// minJWKSRefreshInterval rate limits refreshes triggered by tokens signed with unknown keys
+	minJWKSRefreshInterval = 30 * time.Second
+)
+
+// ErrUnknownSigningKey is returned when a token is signed by a key that is not in the key set
+var ErrUnknownSigningKey = errors.New("token is signed by an unknown key")
+
+// JWKS caches the public keys published by an identity provider's JWKS endpoint.
+// Keys are refreshed periodically and whenever a token refers to an unknown key ID,
+// so key rotation on the provider side is picked up without a restart.
+type JWKS struct {
+	url             string
+	client          *http.Client
+	refreshInterval time.Duration
+
+	// refreshes shares a single fetch of the key set between the requests that need it,
+	// which is done without holding mu so that requests with known keys are not held up
+	refreshes singleflight.Group
+
+	mu          sync.RWMutex
+	keys        map[string]crypto.PublicKey
+	fetchedAt   time.Time
+	lastAttempt time.Time
+}
+
+// jsonWebKey is a single key of a JWKS document
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// NewJWKS creates a key set that fetches its keys from url using client
+func NewJWKS(url string, client *http.Client) *JWKS {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	return &JWKS{
+		url:             url,
+		client:          client,
+		refreshInterval: defaultJWKSRefreshInterval,
+		keys:            make(map[string]crypto.PublicKey),
+	}
+}
+
+// Keyfunc returns a function finding the key a token was signed with, for use with
+// jwt.Parse. Keys fetched on behalf of the token are fetched within ctx.
+func (j *JWKS) Keyfunc(ctx context.Context) jwt.Keyfunc {
+	return func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			return nil, errors.New("token has no kid header")
+		}
+
+		key, found, stale := j.lookup(kid)
+		if found && !stale {
+			return key, nil
+		}
+
+		// Refresh when the keys are stale or the kid is unknown (the provider may have rotated keys)
+		if err := j.refresh(ctx); err != nil && !found {
+			return nil, err
+		}
+
+		if key, found, _ = j.lookup(kid); !found {
+			return nil, ErrUnknownSigningKey
+		}
+
+		return key, nil
+	}
+}
+
+// lookup returns the cached key for kid and whether the cache is due for a refresh
+func (j *JWKS) lookup(kid string) (crypto.PublicKey, bool, bool) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	key, found := j.keys[kid]
+	stale := time.Since(j.fetchedAt) > j.refreshInterval
+
+	return key, found, stale
+}
+
+// refresh fetches the key set again, at most once per minJWKSRefreshInterval. Concurrent
+// callers wait for the same fetch, each giving up when its own ctx is done.
+func (j *JWKS) refresh(ctx context.Context) error {
+	results := j.refreshes.DoChan("keys", func() (any, error) {
+		j.mu.RLock()
+		recent := time.Since(j.lastAttempt) < minJWKSRefreshInterval
+		j.mu.RUnlock()
+		if recent {
+			return nil, nil
+		}
+
+		keys, err := j.fetch(ctx)
+
+		// A fetch abandoned by the request that started it says nothing about the provider
+		if err != nil && ctx.Err() != nil {
+			return nil, err
+		}
+
+		j.mu.Lock()
+		defer j.mu.Unlock()
+
+		j.lastAttempt = time.Now()
+		if err != nil {
+			return nil, err
+		}
+		j.keys = keys
+		j.fetchedAt = j.lastAttempt
+
+		return nil, nil
+	})
+
+	select {
+	case res := <-results:
+		return res.Err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// fetch downloads and parses the key set
+func (j *JWKS) fetch(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create JWKS request: %w", err)
+	}
+
+	res, err := j.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch JWKS: unexpected status %s", res.Status)
+	}
+
+	var doc struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to decode JWKS: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(doc.Keys))
+	for _, k := range doc.Keys {
+		// Skip encryption keys and keys we can't use to verify signatures
+		if k.Kid == "" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+
+		key, err := k.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = key
+	}
+
+	return keys, nil
+}
+
+// publicKey converts a JWK into an RSA or ECDSA public key
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBase64URLInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBase64URLInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBase64URLInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBase64URLInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+// decodeBase64URLInt decodes a base64url encoded big-endian integer
+func decodeBase64URLInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,302 @@
+package graph
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testIssuer   = "https://keycloak.test/realms/betterGR"
+	testAudience = "api-gateway"
+)
+
+// testKeyServer publishes the public keys of generated RSA keys as a JWKS document
+type testKeyServer struct {
+	*httptest.Server
+
+	mu   sync.Mutex
+	keys map[string]*rsa.PrivateKey
+	// held, when set, holds requests for the key set until it is closed
+	held chan struct{}
+
+	// fetches counts the requests for the key set
+	fetches atomic.Int64
+}
+
+func newTestKeyServer(t *testing.T, kids ...string) *testKeyServer {
+	t.Helper()
+
+	s := &testKeyServer{keys: make(map[string]*rsa.PrivateKey)}
+	for _, kid := range kids {
+		s.addKey(t, kid)
+	}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.fetches.Add(1)
+
+		s.mu.Lock()
+		held := s.held
+		s.mu.Unlock()
+		if held != nil {
+			<-held
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		var doc struct {
+			Keys []jsonWebKey `json:"keys"`
+		}
+		for kid, key := range s.keys {
+			doc.Keys = append(doc.Keys, jsonWebKey{
+				Kid: kid,
+				Kty: "RSA",
+				Use: "sig",
+				N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			})
+		}
+		writeJSON(w, http.StatusOK, doc)
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// hold holds the requests for the key set until release is called
+func (s *testKeyServer) hold() (release func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	held := make(chan struct{})
+	s.held = held
+	return func() { close(held) }
+}
+
+// addKey generates a key published under kid
+func (s *testKeyServer) addKey(t *testing.T, kid string) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[kid] = key
+}
+
+// sign returns a token with the given claims signed by the key published under kid, or by a
+// key that is not published when there is none
+func (s *testKeyServer) sign(t *testing.T, kid string, claims jwt.Claims) string {
+	t.Helper()
+
+	s.mu.Lock()
+	key := s.keys[kid]
+	s.mu.Unlock()
+
+	if key == nil {
+		var err error
+		if key, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+// validator returns a validator trusting the keys of the server
+func (s *testKeyServer) validator() *TokenValidator {
+	return NewTokenValidator(AuthConfig{JWKSURL: s.URL, Issuer: testIssuer, Audience: testAudience, ClientID: testAudience}, s.Client())
+}
+
+// validClaims returns the claims of a token the validators of the tests accept
+func validClaims(subject string) *Claims {
+	now := time.Now()
+	claims := &Claims{RealmAccess: RoleSet{Roles: []string{"student"}}}
+	claims.Subject = subject
+	claims.Issuer = testIssuer
+	claims.Audience = jwt.ClaimStrings{testAudience}
+	claims.IssuedAt = jwt.NewNumericDate(now)
+	claims.ExpiresAt = jwt.NewNumericDate(now.Add(5 * time.Minute))
+	return claims
+}
+
+func TestAuthMiddlewareValidatesTokens(t *testing.T) {
+	keys := newTestKeyServer(t, "k1")
+
+	expired := validClaims("s1")
+	expired.IssuedAt = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-10 * time.Minute))
+
+	wrongIssuer := validClaims("s1")
+	wrongIssuer.Issuer = "https://evil.test/realms/betterGR"
+
+	wrongAudience := validClaims("s1")
+	wrongAudience.Audience = jwt.ClaimStrings{"another-client"}
+
+	tests := []struct {
+		name   string
+		header string
+		want   int
+	}{
+		{"valid token", "Bearer " + keys.sign(t, "k1", validClaims("s1")), http.StatusOK},
+		{"no token", "", http.StatusOK},
+		{"expired token", "Bearer " + keys.sign(t, "k1", expired), http.StatusUnauthorized},
+		{"wrong issuer", "Bearer " + keys.sign(t, "k1", wrongIssuer), http.StatusUnauthorized},
+		{"wrong audience", "Bearer " + keys.sign(t, "k1", wrongAudience), http.StatusUnauthorized},
+		{"unknown kid", "Bearer " + keys.sign(t, "k2", validClaims("s1")), http.StatusUnauthorized},
+		{"malformed token", "Bearer not-a-token", http.StatusUnauthorized},
+		{"not a bearer token", "Basic czE6cGFzc3dvcmQ=", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var reached bool
+			h := AuthMiddleware(keys.validator(), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				reached = true
+				if tt.header != "" && GetClaims(r.Context()).Subject != "s1" {
+					t.Errorf("claims of the token are not in the context")
+				}
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, "/query", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("got status %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+			if reached != (tt.want == http.StatusOK) {
+				t.Errorf("next handler reached: %v", reached)
+			}
+			if tt.want == http.StatusUnauthorized {
+				var body graphQLResponse
+				if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+					t.Fatal(err)
+				}
+				if codes := body.errorCodes(); len(codes) != 1 || codes[0] != ErrCodeUnauthenticated {
+					t.Errorf("got error codes %v, want UNAUTHENTICATED", codes)
+				}
+			}
+		})
+	}
+}
+
+func TestJWKSPicksUpRotatedKeys(t *testing.T) {
+	keys := newTestKeyServer(t, "k1")
+	validator := keys.validator()
+
+	if _, err := validator.Validate(context.Background(), keys.sign(t, "k1", validClaims("s1"))); err != nil {
+		t.Fatal(err)
+	}
+
+	// Keys are refreshed at most once per minJWKSRefreshInterval
+	validator.jwks.mu.Lock()
+	validator.jwks.lastAttempt = time.Time{}
+	validator.jwks.mu.Unlock()
+
+	keys.addKey(t, "k2")
+	if _, err := validator.Validate(context.Background(), keys.sign(t, "k2", validClaims("s1"))); err != nil {
+		t.Errorf("token signed with the rotated key: %v", err)
+	}
+	if n := keys.fetches.Load(); n != 2 {
+		t.Errorf("got %d fetches of the key set, want 2", n)
+	}
+}
+
+func TestJWKSSharesConcurrentRefreshes(t *testing.T) {
+	keys := newTestKeyServer(t, "k1")
+	release := keys.hold()
+	validator := keys.validator()
+	token := keys.sign(t, "k1", validClaims("s1"))
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := validator.Validate(context.Background(), token)
+			errs <- err
+		}()
+	}
+
+	// Let the requests queue up behind the first fetch
+	time.Sleep(50 * time.Millisecond)
+	release()
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	if n := keys.fetches.Load(); n != 1 {
+		t.Errorf("got %d fetches of the key set, want 1", n)
+	}
+}
+
+func TestJWKSFetchDoesNotBlockKnownKeys(t *testing.T) {
+	keys := newTestKeyServer(t, "k1")
+	validator := keys.validator()
+
+	if _, err := validator.Validate(context.Background(), keys.sign(t, "k1", validClaims("s1"))); err != nil {
+		t.Fatal(err)
+	}
+
+	// A token signed with an unknown key starts a fetch that hangs
+	defer keys.hold()()
+	validator.jwks.mu.Lock()
+	validator.jwks.lastAttempt = time.Time{}
+	validator.jwks.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	go validator.Validate(ctx, keys.sign(t, "unknown", validClaims("s2")))
+
+	// Tokens signed with known keys are still validated meanwhile
+	done := make(chan error, 1)
+	go func() {
+		_, err := validator.Validate(context.Background(), keys.sign(t, "k1", validClaims("s1")))
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("validating a token signed with a known key waited for the fetch")
+	}
+
+	// The request waiting for the fetch gives up with its context
+	if err := validator.jwks.refresh(ctx); err == nil {
+		t.Error("refresh outlived its context")
+	}
+}
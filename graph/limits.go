@@ -0,0 +1,218 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/99designs/gqlgen/complexity"
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/BetterGR/api-gateway/graph/model"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// Error codes of the operations rejected by QueryLimiter
+const (
+	ErrCodeDepthLimitExceeded      = "DEPTH_LIMIT_EXCEEDED"
+	ErrCodeComplexityLimitExceeded = "COMPLEXITY_LIMIT_EXCEEDED"
+)
+
+const (
+	// backendCallCost is the complexity added by a field that calls a microservice
+	backendCallCost = 5
+	// listSizeEstimate is the number of items lists are assumed to hold, so that the
+	// selections made on their items weigh that many times more
+	listSizeEstimate = 10
+)
+
+// QueryLimits bounds the operations the gateway executes, a limit of 0 disables the check
+type QueryLimits struct {
+	// MaxDepth is the deepest level of nested fields an operation may select
+	MaxDepth int
+	// MaxComplexity is the highest complexity an operation may have, as computed from the
+	// weights set by NewComplexity
+	MaxComplexity int
+}
+
+// LoadQueryLimits builds the query limits from MAX_QUERY_DEPTH and MAX_QUERY_COMPLEXITY
+func LoadQueryLimits() (QueryLimits, error) {
+	maxDepth, err := strconv.Atoi(getEnvOrDefault("MAX_QUERY_DEPTH", "10"))
+	if err != nil || maxDepth < 0 {
+		return QueryLimits{}, errors.New("MAX_QUERY_DEPTH must be a non-negative number")
+	}
+
+	maxComplexity, err := strconv.Atoi(getEnvOrDefault("MAX_QUERY_COMPLEXITY", "1000"))
+	if err != nil || maxComplexity < 0 {
+		return QueryLimits{}, errors.New("MAX_QUERY_COMPLEXITY must be a non-negative number")
+	}
+
+	return QueryLimits{MaxDepth: maxDepth, MaxComplexity: maxComplexity}, nil
+}
+
+// NewComplexity weights the fields resolved by calling a microservice. Fields returning a
+// list fan out, as each item may trigger further calls, so their selections are multiplied
+// by listSizeEstimate. Fields read from an already fetched object keep the default cost of 1.
+func NewComplexity() ComplexityRoot {
+	var c ComplexityRoot
+
+	c.Student.Courses = backendListCost
+	c.Staff.Courses = backendListCost
+	c.Course.Staff = backendListCost
+	c.Course.Students = backendListCost
+	c.Course.Announcements = backendListCost
+	c.Course.Homework = backendListCost
+	c.Course.Grades = backendListCost
+
+	c.Query.Node = func(childComplexity int, _ string) int { return backendObjectCost(childComplexity) }
+	c.Query.Nodes = func(childComplexity int, ids []string) int {
+		return len(ids) * backendObjectCost(childComplexity)
+	}
+	c.Query.Student = func(childComplexity int, _ string) int { return backendObjectCost(childComplexity) }
+	c.Query.Staff = func(childComplexity int, _ string) int { return backendObjectCost(childComplexity) }
+	c.Query.Course = func(childComplexity int, _ string) int { return backendObjectCost(childComplexity) }
+	c.Query.Grade = func(childComplexity int, _ string) int { return backendObjectCost(childComplexity) }
+	c.Query.Homework = func(childComplexity int, _ string) int { return backendObjectCost(childComplexity) }
+	c.Query.Submission = func(childComplexity int, _ string) int { return backendObjectCost(childComplexity) }
+	c.Query.Announcement = func(childComplexity int, _ string) int { return backendObjectCost(childComplexity) }
+
+	c.Query.CourseStudents = func(childComplexity int, _ string) int { return backendListCost(childComplexity) }
+	c.Query.CourseStaff = func(childComplexity int, _ string) int { return backendListCost(childComplexity) }
+	c.Query.StudentCourses = func(childComplexity int, _ string) int { return backendListCost(childComplexity) }
+	c.Query.StaffCourses = func(childComplexity int, _ string) int { return backendListCost(childComplexity) }
+	c.Query.SemesterCourses = func(childComplexity int, _ string) int { return backendListCost(childComplexity) }
+	c.Query.HomeworkByCourse = func(childComplexity int, _ string) int { return backendListCost(childComplexity) }
+	c.Query.SubmissionsByStudent = func(childComplexity int, _ string) int { return backendListCost(childComplexity) }
+	c.Query.AnnouncementsByCourse = func(childComplexity int, _ string) int { return backendListCost(childComplexity) }
+	c.Query.CourseGrades = func(childComplexity int, _, _ string, _ *model.GradeFilter, _ *model.GradeOrder) int {
+		return backendListCost(childComplexity)
+	}
+	c.Query.StudentSemesterGrades = func(childComplexity int, _, _ string, _ *model.GradeFilter, _ *model.GradeOrder) int {
+		return backendListCost(childComplexity)
+	}
+	c.Query.StudentCourseGrades = func(childComplexity int, _, _, _ string, _ *model.GradeFilter, _ *model.GradeOrder) int {
+		return backendListCost(childComplexity)
+	}
+	c.Query.Grades = func(childComplexity int, _, _ *string, _ *model.GradeFilter, _ *model.GradeOrder) int {
+		return backendListCost(childComplexity)
+	}
+
+	// Connections return a page of at most first or last edges
+	c.Query.SemesterCoursesConnection = func(childComplexity int, _ string, first *int32, _ *string, last *int32, _ *string) int {
+		return backendPageCost(childComplexity, first, last)
+	}
+	c.Query.CourseStudentsConnection = func(childComplexity int, _ string, first *int32, _ *string, last *int32, _ *string) int {
+		return backendPageCost(childComplexity, first, last)
+	}
+	c.Query.CourseGradesConnection = func(childComplexity int, _, _ string, _ *model.GradeFilter, _ *model.GradeOrder, first *int32, _ *string, last *int32, _ *string) int {
+		return backendPageCost(childComplexity, first, last)
+	}
+	c.Query.AnnouncementsByCourseConnection = func(childComplexity int, _ string, first *int32, _ *string, last *int32, _ *string) int {
+		return backendPageCost(childComplexity, first, last)
+	}
+
+	return c
+}
+
+// backendObjectCost is the complexity of a field fetching a single object from a microservice
+func backendObjectCost(childComplexity int) int {
+	return backendCallCost + childComplexity
+}
+
+// backendListCost is the complexity of a field fetching a list from a microservice
+func backendListCost(childComplexity int) int {
+	return backendCallCost + listSizeEstimate*childComplexity
+}
+
+// backendPageCost is the complexity of a connection field fetching a page from a microservice
+func backendPageCost(childComplexity int, first, last *int32) int {
+	return backendCallCost + pageSizeEstimate(first, last)*childComplexity
+}
+
+// QueryLimiter is a gqlgen extension rejecting operations that are nested too deeply or that
+// are too complex, before any of their fields are resolved
+type QueryLimiter struct {
+	limits QueryLimits
+	schema graphql.ExecutableSchema
+}
+
+var (
+	_ graphql.HandlerExtension        = (*QueryLimiter)(nil)
+	_ graphql.OperationContextMutator = (*QueryLimiter)(nil)
+)
+
+// NewQueryLimiter creates the extension enforcing limits
+func NewQueryLimiter(limits QueryLimits) *QueryLimiter {
+	return &QueryLimiter{limits: limits}
+}
+
+// ExtensionName identifies the extension to gqlgen
+func (l *QueryLimiter) ExtensionName() string {
+	return "QueryLimiter"
+}
+
+// Validate keeps the schema the complexity is computed against
+func (l *QueryLimiter) Validate(schema graphql.ExecutableSchema) error {
+	l.schema = schema
+	return nil
+}
+
+// MutateOperationContext checks the depth and complexity of the operation about to be executed
+func (l *QueryLimiter) MutateOperationContext(ctx context.Context, oc *graphql.OperationContext) *gqlerror.Error {
+	if oc.Operation == nil {
+		return nil
+	}
+
+	if l.limits.MaxDepth > 0 {
+		if depth := selectionDepth(oc.Operation.SelectionSet); depth > l.limits.MaxDepth {
+			err := gqlerror.Errorf("operation has a depth of %d, which exceeds the limit of %d; select fewer levels of nested fields", depth, l.limits.MaxDepth)
+			err.Extensions = map[string]any{
+				"code":     ErrCodeDepthLimitExceeded,
+				"depth":    depth,
+				"maxDepth": l.limits.MaxDepth,
+			}
+			return err
+		}
+	}
+
+	if l.limits.MaxComplexity > 0 {
+		cost := complexity.Calculate(ctx, l.schema, oc.Operation, oc.Variables)
+		if cost > l.limits.MaxComplexity {
+			err := gqlerror.Errorf("operation has a complexity of %d, which exceeds the limit of %d; select fewer nested lists", cost, l.limits.MaxComplexity)
+			err.Extensions = map[string]any{
+				"code":          ErrCodeComplexityLimitExceeded,
+				"complexity":    cost,
+				"maxComplexity": l.limits.MaxComplexity,
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+// selectionDepth returns the deepest level of fields in a selection set, following fragments.
+// Introspection fields are not counted, so that tools can always load the schema.
+func selectionDepth(set ast.SelectionSet) int {
+	deepest := 0
+	for _, selection := range set {
+		var depth int
+		switch s := selection.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(s.Name, "__") {
+				continue
+			}
+			depth = 1 + selectionDepth(s.SelectionSet)
+		case *ast.InlineFragment:
+			depth = selectionDepth(s.SelectionSet)
+		case *ast.FragmentSpread:
+			if s.Definition != nil {
+				depth = selectionDepth(s.Definition.SelectionSet)
+			}
+		}
+		deepest = max(deepest, depth)
+	}
+
+	return deepest
+}
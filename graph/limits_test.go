@@ -0,0 +1,156 @@
+package graph
+
+import (
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/BetterGR/api-gateway/graph/model"
+)
+
+// limitedServer serves the fake school as an admin, with the weights and limits of the gateway
+func limitedServer(limits QueryLimits) *handler.Server {
+	r := newFakeSchool().resolver()
+	srv := handler.New(NewExecutableSchema(Config{Resolvers: r, Directives: NewDirectives(), Complexity: NewComplexity()}))
+	srv.AddTransport(transport.POST{})
+	srv.Use(extension.Introspection{})
+	srv.SetErrorPresenter(NewErrorPresenter(false))
+	srv.Use(NewLoaderExtension(r))
+	srv.Use(NewQueryLimiter(limits))
+	return srv
+}
+
+func TestQueryLimiter(t *testing.T) {
+	tests := []struct {
+		name       string
+		limits     QueryLimits
+		query      string
+		wantCode   string
+		wantExtras map[string]any
+	}{
+		{
+			name:   "depth at the limit",
+			limits: QueryLimits{MaxDepth: 3},
+			query:  `{ student(id: "s1") { courses { id } } }`,
+		},
+		{
+			name:       "depth over the limit",
+			limits:     QueryLimits{MaxDepth: 3},
+			query:      `{ student(id: "s1") { courses { students { id } } } }`,
+			wantCode:   ErrCodeDepthLimitExceeded,
+			wantExtras: map[string]any{"depth": 4.0, "maxDepth": 3.0},
+		},
+		{
+			name:       "depth over the limit through fragments",
+			limits:     QueryLimits{MaxDepth: 3},
+			query:      `{ student(id: "s1") { ...courses } } fragment courses on Student { courses { ... on Course { students { id } } } }`,
+			wantCode:   ErrCodeDepthLimitExceeded,
+			wantExtras: map[string]any{"depth": 4.0, "maxDepth": 3.0},
+		},
+		{
+			name:   "introspection is not counted",
+			limits: QueryLimits{MaxDepth: 1},
+			query:  `{ __schema { types { fields { type { ofType { name } } } } } }`,
+		},
+		{
+			// 5 for the call and 10 items of 5 + 10 students each
+			name:   "complexity at the limit",
+			limits: QueryLimits{MaxComplexity: 155},
+			query:  `{ studentCourses(studentId: "s1") { students { id } } }`,
+		},
+		{
+			name:       "complexity over the limit",
+			limits:     QueryLimits{MaxComplexity: 154},
+			query:      `{ studentCourses(studentId: "s1") { students { id } } }`,
+			wantCode:   ErrCodeComplexityLimitExceeded,
+			wantExtras: map[string]any{"complexity": 155.0, "maxComplexity": 154.0},
+		},
+		{
+			// A single object costs the call and its fields only
+			name:   "single object",
+			limits: QueryLimits{MaxComplexity: 7},
+			query:  `{ student(id: "s1") { id firstName } }`,
+		},
+		{
+			// Pages weigh the number of edges asked for
+			name:       "page over the limit",
+			limits:     QueryLimits{MaxComplexity: 100},
+			query:      `{ courseStudentsConnection(courseId: "c1", first: 50) { edges { node { id } } } }`,
+			wantCode:   ErrCodeComplexityLimitExceeded,
+			wantExtras: map[string]any{"maxComplexity": 100.0},
+		},
+		{
+			name:   "limits disabled",
+			limits: QueryLimits{},
+			query:  `{ student(id: "s1") { courses { students { courses { students { id } } } } } }`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := withClaims("a1", []string{model.RoleAdmin.String()}, limitedServer(tt.limits))
+			res := execute(t, h, tt.query, nil)
+
+			if tt.wantCode == "" {
+				if len(res.Errors) > 0 {
+					t.Fatalf("unexpected errors %v", res.Errors)
+				}
+				return
+			}
+
+			if codes := res.errorCodes(); !slices.Equal(codes, []string{tt.wantCode}) {
+				t.Fatalf("got error codes %v, want %s", codes, tt.wantCode)
+			}
+			if string(res.Data) != "" && string(res.Data) != "null" {
+				t.Errorf("got data %s, want none", res.Data)
+			}
+			for key, want := range tt.wantExtras {
+				if got := res.Errors[0].Extensions[key]; got != want {
+					t.Errorf("got %s %v, want %v", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadQueryLimits(t *testing.T) {
+	t.Setenv("MAX_QUERY_DEPTH", "")
+	t.Setenv("MAX_QUERY_COMPLEXITY", "")
+	if _, err := LoadQueryLimits(); err == nil {
+		t.Error("accepted empty limits")
+	}
+
+	t.Setenv("MAX_QUERY_DEPTH", "-1")
+	t.Setenv("MAX_QUERY_COMPLEXITY", "500")
+	if _, err := LoadQueryLimits(); err == nil {
+		t.Error("accepted a negative depth")
+	}
+
+	t.Setenv("MAX_QUERY_DEPTH", "0")
+	limits, err := LoadQueryLimits()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limits != (QueryLimits{MaxDepth: 0, MaxComplexity: 500}) {
+		t.Errorf("got %+v, want the depth check disabled and a complexity of 500", limits)
+	}
+}
+
+func TestLoadQueryLimitsDefaults(t *testing.T) {
+	// Clear the variables for the test only, t.Setenv restores them afterwards
+	for _, name := range []string{"MAX_QUERY_DEPTH", "MAX_QUERY_COMPLEXITY"} {
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+
+	limits, err := LoadQueryLimits()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limits != (QueryLimits{MaxDepth: 10, MaxComplexity: 1000}) {
+		t.Errorf("got %+v, want a depth of 10 and a complexity of 1000", limits)
+	}
+}
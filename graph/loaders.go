@@ -0,0 +1,229 @@
+package graph
+
+import (
+	"context"
+	"sync"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/BetterGR/api-gateway/graph/dataloader"
+	"github.com/BetterGR/api-gateway/graph/model"
+	coursespb "github.com/BetterGR/courses-microservice/protos"
+	gradespb "github.com/BetterGR/grades-microservice/protos"
+	staffpb "github.com/BetterGR/staff-microservice/protos"
+	studentspb "github.com/BetterGR/students-microservice/protos"
+)
+
+const (
+	// LoadersKey is the key used to store the loaders of the response being resolved in the context
+	LoadersKey = contextKey("loaders")
+
+	// maxConcurrentBackendCalls bounds how many calls a single batch makes to a microservice at once
+	maxConcurrentBackendCalls = 10
+)
+
+// courseSemesterKey identifies the grades of a course in a given semester
+type courseSemesterKey struct {
+	CourseID string
+	Semester string
+}
+
+// Loaders holds the dataloaders used to batch and dedupe gRPC lookups during a single response
+type Loaders struct {
+	Students     *dataloader.Loader[string, *studentspb.Student]
+	Staff        *dataloader.Loader[string, *staffpb.StaffMember]
+	Courses      *dataloader.Loader[string, *coursespb.Course]
+	CourseGrades *dataloader.Loader[courseSemesterKey, []*gradespb.SingleGrade]
+	Homework     *dataloader.Loader[string, *model.Homework]
+
+	// Homework assigned in a course, keyed by course ID
+	CourseHomework *dataloader.Loader[string, []*model.Homework]
+
+	// Membership lists, keyed by the ID of the owning course, student or staff member
+	CourseStudentIDs *dataloader.Loader[string, []string]
+	CourseStaffIDs   *dataloader.Loader[string, []string]
+	StudentCourseIDs *dataloader.Loader[string, []string]
+	StaffCourseIDs   *dataloader.Loader[string, []string]
+}
+
+// NewLoaders creates a fresh set of loaders backed by the resolver's gRPC clients
+func NewLoaders(r *Resolver) *Loaders {
+	return &Loaders{
+		Students: dataloader.New(func(ctx context.Context, ids []string) ([]*studentspb.Student, []error) {
+			authCtx, token := r.CreateAuthContext(ctx), r.GetAuthTokenForRequest(ctx)
+			return fetchEach(ids, func(id string) (*studentspb.Student, error) {
+				res, err := r.StudentsClient.GetStudent(authCtx, &studentspb.GetStudentRequest{StudentID: id, Token: token})
+				if err != nil {
+					return nil, err
+				}
+				return res.Student, nil
+			})
+		}),
+		Staff: dataloader.New(func(ctx context.Context, ids []string) ([]*staffpb.StaffMember, []error) {
+			authCtx, token := r.CreateAuthContext(ctx), r.GetAuthTokenForRequest(ctx)
+			return fetchEach(ids, func(id string) (*staffpb.StaffMember, error) {
+				res, err := r.StaffClient.GetStaffMember(authCtx, &staffpb.GetStaffMemberRequest{StaffID: id, Token: token})
+				if err != nil {
+					return nil, err
+				}
+				return res.StaffMember, nil
+			})
+		}),
+		Courses: dataloader.New(func(ctx context.Context, ids []string) ([]*coursespb.Course, []error) {
+			authCtx, token := r.CreateAuthContext(ctx), r.GetAuthTokenForRequest(ctx)
+			return fetchEach(ids, func(id string) (*coursespb.Course, error) {
+				res, err := r.CoursesClient.GetCourse(authCtx, &coursespb.GetCourseRequest{CourseID: id, Token: token})
+				if err != nil {
+					return nil, err
+				}
+				return res.Course, nil
+			})
+		}),
+		CourseGrades: dataloader.New(func(ctx context.Context, keys []courseSemesterKey) ([][]*gradespb.SingleGrade, []error) {
+			authCtx, token := r.CreateAuthContext(ctx), r.GetAuthTokenForRequest(ctx)
+			return fetchEach(keys, func(key courseSemesterKey) ([]*gradespb.SingleGrade, error) {
+				res, err := r.GradesClient.GetCourseGrades(authCtx, &gradespb.GetCourseGradesRequest{
+					CourseID: key.CourseID,
+					Semester: key.Semester,
+					Token:    token,
+				})
+				if err != nil {
+					return nil, err
+				}
+				return res.Grades, nil
+			})
+		}),
+		Homework: dataloader.New(func(ctx context.Context, ids []string) ([]*model.Homework, []error) {
+			authCtx := r.CreateAuthContext(ctx)
+			return fetchEach(ids, func(id string) (*model.Homework, error) {
+				client, err := r.homeworkClient()
+				if err != nil {
+					return nil, err
+				}
+				return client.GetHomework(authCtx, id)
+			})
+		}),
+		CourseHomework: dataloader.New(func(ctx context.Context, ids []string) ([][]*model.Homework, []error) {
+			authCtx := r.CreateAuthContext(ctx)
+			return fetchEach(ids, func(id string) ([]*model.Homework, error) {
+				client, err := r.homeworkClient()
+				if err != nil {
+					return nil, err
+				}
+				return client.GetCourseHomework(authCtx, id)
+			})
+		}),
+		CourseStudentIDs: dataloader.New(func(ctx context.Context, ids []string) ([][]string, []error) {
+			authCtx, token := r.CreateAuthContext(ctx), r.GetAuthTokenForRequest(ctx)
+			return fetchEach(ids, func(id string) ([]string, error) {
+				res, err := r.CoursesClient.GetCourseStudents(authCtx, &coursespb.GetCourseStudentsRequest{CourseID: id, Token: token})
+				if err != nil {
+					return nil, err
+				}
+				return res.StudentsIDs, nil
+			})
+		}),
+		CourseStaffIDs: dataloader.New(func(ctx context.Context, ids []string) ([][]string, []error) {
+			authCtx, token := r.CreateAuthContext(ctx), r.GetAuthTokenForRequest(ctx)
+			return fetchEach(ids, func(id string) ([]string, error) {
+				res, err := r.CoursesClient.GetCourseStaff(authCtx, &coursespb.GetCourseStaffRequest{CourseID: id, Token: token})
+				if err != nil {
+					return nil, err
+				}
+				return res.StaffIDs, nil
+			})
+		}),
+		StudentCourseIDs: dataloader.New(func(ctx context.Context, ids []string) ([][]string, []error) {
+			authCtx, token := r.CreateAuthContext(ctx), r.GetAuthTokenForRequest(ctx)
+			return fetchEach(ids, func(id string) ([]string, error) {
+				res, err := r.CoursesClient.GetStudentCourses(authCtx, &coursespb.GetStudentCoursesRequest{StudentID: id, Token: token})
+				if err != nil {
+					return nil, err
+				}
+				return res.CoursesIDs, nil
+			})
+		}),
+		StaffCourseIDs: dataloader.New(func(ctx context.Context, ids []string) ([][]string, []error) {
+			authCtx, token := r.CreateAuthContext(ctx), r.GetAuthTokenForRequest(ctx)
+			return fetchEach(ids, func(id string) ([]string, error) {
+				res, err := r.CoursesClient.GetStaffCourses(authCtx, &coursespb.GetStaffCoursesRequest{StaffID: id, Token: token})
+				if err != nil {
+					return nil, err
+				}
+				return res.CoursesIDs, nil
+			})
+		}),
+	}
+}
+
+// LoaderExtension is a gqlgen extension attaching a fresh set of loaders to every response, so
+// that lookups are batched and cached for the lifetime of a single query or mutation. A
+// subscription produces a response per event, and each event gets its own loaders: a websocket
+// connection lives for as long as the client stays, and the records read for one event must not
+// be served from a cache filled by an earlier one.
+type LoaderExtension struct {
+	resolver *Resolver
+}
+
+var (
+	_ graphql.HandlerExtension    = LoaderExtension{}
+	_ graphql.ResponseInterceptor = LoaderExtension{}
+)
+
+// NewLoaderExtension creates the extension for the loaders backed by the resolver's gRPC clients
+func NewLoaderExtension(resolver *Resolver) LoaderExtension {
+	return LoaderExtension{resolver: resolver}
+}
+
+// ExtensionName identifies the extension to gqlgen
+func (LoaderExtension) ExtensionName() string {
+	return "Loaders"
+}
+
+// Validate is called by gqlgen when the extension is registered
+func (LoaderExtension) Validate(graphql.ExecutableSchema) error {
+	return nil
+}
+
+// InterceptResponse resolves the fields of a response with a fresh set of loaders
+func (e LoaderExtension) InterceptResponse(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+	return next(context.WithValue(ctx, LoadersKey, NewLoaders(e.resolver)))
+}
+
+// GetLoaders gets the loaders of the response being resolved from the context
+func GetLoaders(ctx context.Context) *Loaders {
+	if loaders, ok := ctx.Value(LoadersKey).(*Loaders); ok {
+		return loaders
+	}
+	return nil
+}
+
+// loaders returns the loaders of the response being resolved, or a throwaway set outside of
+// the responses resolved through LoaderExtension
+func (r *Resolver) loaders(ctx context.Context) *Loaders {
+	if loaders := GetLoaders(ctx); loaders != nil {
+		return loaders
+	}
+	return NewLoaders(r)
+}
+
+// fetchEach calls fetch for every key with bounded concurrency. The microservices have
+// no bulk lookup endpoints, so a batch is fanned out into parallel single lookups.
+func fetchEach[K comparable, V any](keys []K, fetch func(key K) (V, error)) ([]V, []error) {
+	values := make([]V, len(keys))
+	errs := make([]error, len(keys))
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxConcurrentBackendCalls)
+	for i, key := range keys {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			values[i], errs[i] = fetch(key)
+		}()
+	}
+	wg.Wait()
+
+	return values, errs
+}
@@ -0,0 +1,29 @@
+package graph
+
+import (
+	"context"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql"
+)
+
+func TestEveryResponseGetsItsOwnLoaders(t *testing.T) {
+	ext := NewLoaderExtension(newFakeSchool().resolver())
+
+	// A subscription resolves every event as a response of the same operation
+	var seen []*Loaders
+	ctx := context.Background()
+	for range 2 {
+		ext.InterceptResponse(ctx, func(ctx context.Context) *graphql.Response {
+			seen = append(seen, GetLoaders(ctx))
+			return nil
+		})
+	}
+
+	if seen[0] == nil || seen[1] == nil {
+		t.Fatal("response resolved without loaders")
+	}
+	if seen[0] == seen[1] {
+		t.Error("two responses share their loaders")
+	}
+}
@@ -0,0 +1,326 @@
+package graph
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+)
+
+const (
+	// loggerKey is the key used to store the request logger in the context
+	loggerKey = contextKey("logger")
+	// accessLogKey is the key used to store the details of the access log line in the context
+	accessLogKey = contextKey("access_log")
+
+	// redacted replaces secrets and personal data in log lines
+	redacted = "[REDACTED]"
+
+	// requestIDHeader carries the ID of a request from the client and back in the response
+	requestIDHeader = "X-Request-ID"
+)
+
+// LogSettings configures the logger of the gateway
+type LogSettings struct {
+	Level slog.Level
+	// Format is json or text
+	Format string
+}
+
+// LoadLogSettings builds the log settings from LOG_LEVEL and LOG_FORMAT
+func LoadLogSettings() (LogSettings, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(getEnvOrDefault("LOG_LEVEL", "info"))); err != nil {
+		return LogSettings{}, errors.New("LOG_LEVEL must be debug, info, warn or error")
+	}
+
+	format := strings.ToLower(getEnvOrDefault("LOG_FORMAT", "json"))
+	if format != "json" && format != "text" {
+		return LogSettings{}, fmt.Errorf("LOG_FORMAT must be json or text, got %q", format)
+	}
+
+	return LogSettings{Level: level, Format: format}, nil
+}
+
+// NewLogger creates a logger writing to w that redacts tokens and personal data
+func NewLogger(w io.Writer, settings LogSettings) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: settings.Level, ReplaceAttr: redactAttr}
+	if settings.Format == "text" {
+		return slog.New(slog.NewTextHandler(w, opts))
+	}
+	return slog.New(slog.NewJSONHandler(w, opts))
+}
+
+// sensitiveLogKeys are the attributes whose values are never logged, compared in lower case
+var sensitiveLogKeys = map[string]bool{
+	"authorization": true,
+	"cookie":        true,
+	"token":         true,
+	"accesstoken":   true,
+	"password":      true,
+	"secret":        true,
+	"email":         true,
+	"phonenumber":   true,
+	"firstname":     true,
+	"lastname":      true,
+}
+
+// sensitiveLogPatterns find secrets and personal data embedded in messages and errors
+var sensitiveLogPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)bearer\s+\S+`),
+	regexp.MustCompile(`eyJ[\w-]+\.[\w-]+\.[\w-]*`),
+	regexp.MustCompile(`[\w.%+-]+@[\w-]+(\.[\w-]+)+`),
+}
+
+// redactAttr hides the values of sensitive attributes, and the tokens and email addresses
+// found in any other string or error
+func redactAttr(_ []string, a slog.Attr) slog.Attr {
+	if sensitiveLogKeys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, redacted)
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindString:
+		a.Value = slog.StringValue(redactString(a.Value.String()))
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			a.Value = slog.StringValue(redactString(err.Error()))
+		}
+	}
+
+	return a
+}
+
+// redactString replaces every match of the sensitive patterns in s
+func redactString(s string) string {
+	for _, pattern := range sensitiveLogPatterns {
+		s = pattern.ReplaceAllString(s, redacted)
+	}
+	return s
+}
+
+// LoggerFromContext returns the logger of the current request, or the default logger outside
+// of a request
+func LoggerFromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// logger returns the logger of the current request, falling back to the logger of the resolver
+func (r *Resolver) logger(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey).(*slog.Logger); ok {
+		return logger
+	}
+	if r.Logger != nil {
+		return r.Logger
+	}
+	return slog.Default()
+}
+
+// annotateRequest adds an attribute to the request logger and to the access log line
+func annotateRequest(ctx context.Context, key, value string) context.Context {
+	if entry, ok := ctx.Value(accessLogKey).(*accessLogEntry); ok {
+		entry.set(key, value)
+	}
+	return context.WithValue(ctx, loggerKey, LoggerFromContext(ctx).With(key, value))
+}
+
+// accessLogEntry collects the attributes added to a request while it is served
+type accessLogEntry struct {
+	mu    sync.Mutex
+	attrs []slog.Attr
+}
+
+// set adds an attribute, replacing any earlier value of key
+func (e *accessLogEntry) set(key, value string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.attrs = slices.DeleteFunc(e.attrs, func(a slog.Attr) bool { return a.Key == key })
+	e.attrs = append(e.attrs, slog.String(key, value))
+}
+
+// RequestLogMiddleware gives every request an ID and a logger carrying it, and writes an
+// access log line once the request is served. Requests to quietPaths, such as probes and
+// metrics scrapes, are only logged at debug level. Query strings are left out of the log
+// as they may carry variables.
+func RequestLogMiddleware(logger *slog.Logger, quietPaths []string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		requestID := r.Header.Get(requestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, requestID)
+
+		entry := &accessLogEntry{}
+		ctx := context.WithValue(r.Context(), accessLogKey, entry)
+		ctx = context.WithValue(ctx, loggerKey, logger.With("requestId", requestID))
+
+		rec := &statusRecorder{writerWrapper: writerWrapper{w}}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		level := slog.LevelInfo
+		switch {
+		case rec.status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case slices.Contains(quietPaths, r.URL.Path):
+			level = slog.LevelDebug
+		}
+
+		attrs := []slog.Attr{
+			slog.String("requestId", requestID),
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", rec.statusCode()),
+			slog.Int64("bytes", rec.bytes),
+			slog.Float64("durationMs", float64(time.Since(start).Microseconds())/1000),
+		}
+		entry.mu.Lock()
+		attrs = append(attrs, entry.attrs...)
+		entry.mu.Unlock()
+
+		logger.LogAttrs(r.Context(), level, "request", attrs...)
+	})
+}
+
+// validRequestID reports whether a client-provided request ID can be used as is
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID generates a random request ID
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// errHijackNotSupported is returned when the underlying writer cannot hand over the connection
+var errHijackNotSupported = errors.New("the response writer does not support hijacking")
+
+// writerWrapper is embedded by the middlewares wrapping the response writer of a request.
+// It lets WebSocket upgrades hijack the connection and streamed responses flush through
+// the wrapper, which the embedded http.ResponseWriter alone does not expose.
+type writerWrapper struct {
+	http.ResponseWriter
+}
+
+// Flush sends any buffered data to the client
+func (w writerWrapper) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack hands the connection over, as WebSocket upgrades do
+func (w writerWrapper) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errHijackNotSupported
+	}
+	return hijacker.Hijack()
+}
+
+// Unwrap gives http.ResponseController access to the underlying writer
+func (w writerWrapper) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// statusRecorder captures the status code and size of a response
+type statusRecorder struct {
+	writerWrapper
+	status int
+	bytes  int64
+}
+
+// WriteHeader records the status code
+func (w *statusRecorder) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write counts the bytes of the body
+func (w *statusRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Hijack records the switch of protocols of WebSocket upgrades
+func (w *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := w.writerWrapper.Hijack()
+	if err == nil {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+// statusCode returns the recorded status, 200 when nothing was written
+func (w *statusRecorder) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// RequestLogging is a gqlgen extension adding the name of the operation to the request logger
+type RequestLogging struct{}
+
+var (
+	_ graphql.HandlerExtension     = RequestLogging{}
+	_ graphql.OperationInterceptor = RequestLogging{}
+)
+
+// ExtensionName identifies the extension to gqlgen
+func (RequestLogging) ExtensionName() string {
+	return "RequestLogging"
+}
+
+// Validate is called by gqlgen when the extension is registered
+func (RequestLogging) Validate(graphql.ExecutableSchema) error {
+	return nil
+}
+
+// InterceptOperation annotates the request with the name of the operation being executed
+func (RequestLogging) InterceptOperation(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+	oc := graphql.GetOperationContext(ctx)
+	if oc.Operation != nil {
+		name := oc.Operation.Name
+		if name == "" {
+			name = "anonymous"
+		}
+		ctx = annotateRequest(ctx, "operation", name)
+	}
+
+	return next(ctx)
+}
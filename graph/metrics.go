@@ -0,0 +1,217 @@
+package graph
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// metricsNamespace prefixes the names of all the gateway's metrics
+	metricsNamespace = "gateway"
+
+	// otherOperation labels the operations whose name is not known to the gateway
+	otherOperation = "other"
+)
+
+// Metrics holds the Prometheus collectors of the gateway. It is registered with srv.Use as
+// a gqlgen extension for the GraphQL metrics and instruments the gRPC connections through
+// client interceptors.
+type Metrics struct {
+	registry *prometheus.Registry
+	// operations are the operation names measured under their own label. Names are chosen by
+	// the clients, so any other name is measured as "other" to bound the number of series.
+	operations map[string]bool
+
+	operationDuration *prometheus.HistogramVec
+	inFlight          prometheus.Gauge
+	errors            *prometheus.CounterVec
+	apqLookups        *prometheus.CounterVec
+	grpcDuration      *prometheus.HistogramVec
+}
+
+var (
+	_ graphql.HandlerExtension    = (*Metrics)(nil)
+	_ graphql.ResponseInterceptor = (*Metrics)(nil)
+)
+
+// LoadMetricsOperations reads the names of the operations measured under their own label from
+// METRICS_OPERATIONS, a comma-separated list
+func LoadMetricsOperations() []string {
+	var operations []string
+	for _, name := range strings.Split(getEnvOrDefault("METRICS_OPERATIONS", ""), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			operations = append(operations, name)
+		}
+	}
+
+	return operations
+}
+
+// NewMetrics creates the collectors on a dedicated registry, along with the Go runtime and process
+// collectors. Operations are labelled with their name only when it is listed in operations.
+func NewMetrics(operations []string) *Metrics {
+	m := &Metrics{
+		registry:   prometheus.NewRegistry(),
+		operations: make(map[string]bool, len(operations)),
+		operationDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "graphql",
+			Name:      "operation_duration_seconds",
+			Help:      "Duration of GraphQL queries and mutations, from receiving the request to writing the response.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"operation", "type"}),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "graphql",
+			Name:      "operations_in_flight",
+			Help:      "Number of GraphQL queries and mutations being executed.",
+		}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "graphql",
+			Name:      "errors_total",
+			Help:      "Errors reported in GraphQL responses, by the field that raised them and their code.",
+		}, []string{"field", "code"}),
+		apqLookups: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "apq",
+			Name:      "cache_lookups_total",
+			Help:      "Lookups of automatic persisted queries, by result (hit or miss).",
+		}, []string{"result"}),
+		grpcDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "grpc_client",
+			Name:      "duration_seconds",
+			Help:      "Duration of the calls to the microservices, including retries, by service, method and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"service", "method", "code"}),
+	}
+
+	for _, name := range operations {
+		m.operations[name] = true
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.operationDuration,
+		m.inFlight,
+		m.errors,
+		m.apqLookups,
+		m.grpcDuration,
+	)
+
+	return m
+}
+
+// Handler serves the metrics in the Prometheus exposition format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// ExtensionName identifies the extension to gqlgen
+func (m *Metrics) ExtensionName() string {
+	return "Metrics"
+}
+
+// Validate is called by gqlgen when the extension is registered
+func (m *Metrics) Validate(graphql.ExecutableSchema) error {
+	return nil
+}
+
+// InterceptResponse measures the latency and concurrency of queries and mutations.
+// Subscriptions are long-lived and produce a response per event, so they are not measured.
+func (m *Metrics) InterceptResponse(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+	oc := graphql.GetOperationContext(ctx)
+	if oc.Operation == nil || oc.Operation.Operation == ast.Subscription {
+		return next(ctx)
+	}
+
+	m.inFlight.Inc()
+	defer m.inFlight.Dec()
+
+	res := next(ctx)
+
+	m.operationDuration.WithLabelValues(m.operationLabel(oc.Operation.Name), string(oc.Operation.Operation)).
+		Observe(time.Since(oc.Stats.OperationStart).Seconds())
+
+	return res
+}
+
+// operationLabel returns the value of the operation label for the operation called name
+func (m *Metrics) operationLabel(name string) string {
+	switch {
+	case name == "":
+		return "anonymous"
+	case m.operations[name]:
+		return name
+	default:
+		return otherOperation
+	}
+}
+
+// ErrorPresenter counts every error reported in a response before handing it to next. Errors
+// pass through the presenter with the context of the field that raised them, including the
+// per-item errors of partial results, so they can be attributed to that field.
+func (m *Metrics) ErrorPresenter(next graphql.ErrorPresenterFunc) graphql.ErrorPresenterFunc {
+	return func(ctx context.Context, err error) *gqlerror.Error {
+		gqlErr := next(ctx, err)
+
+		field := ""
+		if fc := graphql.GetFieldContext(ctx); fc != nil && fc.Field.Field != nil {
+			field = fc.Object + "." + fc.Field.Name
+		}
+		code, _ := gqlErr.Extensions["code"].(string)
+		m.errors.WithLabelValues(field, code).Inc()
+
+		return gqlErr
+	}
+}
+
+// InstrumentAPQCache counts the hits and misses of the automatic persisted query cache
+func (m *Metrics) InstrumentAPQCache(cache graphql.Cache[string]) graphql.Cache[string] {
+	return &apqCacheMetrics{Cache: cache, lookups: m.apqLookups}
+}
+
+// apqCacheMetrics wraps the automatic persisted query cache to count its lookups
+type apqCacheMetrics struct {
+	graphql.Cache[string]
+	lookups *prometheus.CounterVec
+}
+
+// Get looks up a persisted query and records whether it was found
+func (c *apqCacheMetrics) Get(ctx context.Context, key string) (string, bool) {
+	query, ok := c.Cache.Get(ctx, key)
+	if ok {
+		c.lookups.WithLabelValues("hit").Inc()
+	} else {
+		c.lookups.WithLabelValues("miss").Inc()
+	}
+
+	return query, ok
+}
+
+// interceptor measures the calls made to a microservice
+func (m *Metrics) interceptor(service string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+
+		methodName := method[strings.LastIndex(method, "/")+1:]
+		m.grpcDuration.WithLabelValues(service, methodName, status.Code(err).String()).
+			Observe(time.Since(start).Seconds())
+
+		return err
+	}
+}
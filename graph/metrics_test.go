@@ -0,0 +1,51 @@
+package graph
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/BetterGR/api-gateway/graph/model"
+)
+
+func TestMetricsOperationLabelIsBounded(t *testing.T) {
+	metrics := NewMetrics([]string{"Profile"})
+	r := newFakeSchool().resolver()
+	gql := newTestServer(r)
+	gql.Use(metrics)
+	h := withClaims("s1", []string{model.RoleStudent.String()}, gql)
+
+	for _, query := range []string{
+		`query Profile { student(id: "s1") { id } }`,
+		`query Attacker1 { student(id: "s1") { id } }`,
+		`query Attacker2 { student(id: "s1") { id } }`,
+		`{ student(id: "s1") { id } }`,
+	} {
+		if res := execute(t, h, query, nil); len(res.Errors) > 0 {
+			t.Fatalf("%s: unexpected errors %v", query, res.Errors)
+		}
+	}
+
+	families, err := metrics.registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var operations []string
+	for _, family := range families {
+		if family.GetName() != "gateway_graphql_operation_duration_seconds" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "operation" {
+					operations = append(operations, label.GetValue())
+				}
+			}
+		}
+	}
+	slices.Sort(operations)
+
+	if want := []string{"Profile", "anonymous", "other"}; !slices.Equal(operations, want) {
+		t.Errorf("got operation labels %v, want %v", operations, want)
+	}
+}
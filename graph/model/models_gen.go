@@ -2,33 +2,71 @@
 
 package model
 
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// An object that can be refetched with node(id). Its ID is a globally unique, opaque global ID rather than the ID of the object in its microservice.
+type Node interface {
+	IsNode()
+	GetID() string
+}
+
 type Announcement struct {
-	ID        string `json:"id"`
-	CourseID  string `json:"courseId"`
 	Title     string `json:"title"`
 	Content   string `json:"content"`
 	CreatedAt string `json:"createdAt"`
 	UpdatedAt string `json:"updatedAt"`
+	// Local ID of the course, the courseId field returns its global ID
+	CourseID string `json:"courseId"`
+	// Local ID of the object in its microservice, the id field returns its global ID
+	ID string `json:"id"`
+}
+
+func (Announcement) IsNode()            {}
+func (this Announcement) GetID() string { return this.ID }
+
+type AnnouncementConnection struct {
+	Edges    []*AnnouncementEdge `json:"edges"`
+	PageInfo *PageInfo           `json:"pageInfo"`
+	// Number of announcements in the whole list, across all pages.
+	TotalCount int32 `json:"totalCount"`
+}
+
+type AnnouncementEdge struct {
+	Cursor string        `json:"cursor"`
+	Node   *Announcement `json:"node"`
 }
 
 type Course struct {
-	ID            string          `json:"id"`
-	Name          string          `json:"name"`
-	Semester      string          `json:"semester"`
-	Description   *string         `json:"description,omitempty"`
-	CreatedAt     string          `json:"createdAt"`
-	UpdatedAt     string          `json:"updatedAt"`
-	Staff         []*Staff        `json:"staff"`
-	Students      []*Student      `json:"students"`
-	Announcements []*Announcement `json:"announcements"`
-	Homework      []*Homework     `json:"homework"`
-	Grades        []*Grade        `json:"grades"`
+	Name        string  `json:"name"`
+	Semester    string  `json:"semester"`
+	Description *string `json:"description,omitempty"`
+	CreatedAt   string  `json:"createdAt"`
+	UpdatedAt   string  `json:"updatedAt"`
+	// Local ID of the object in its microservice, the id field returns its global ID
+	ID string `json:"id"`
+}
+
+func (Course) IsNode()            {}
+func (this Course) GetID() string { return this.ID }
+
+type CourseConnection struct {
+	Edges    []*CourseEdge `json:"edges"`
+	PageInfo *PageInfo     `json:"pageInfo"`
+	// Number of courses in the whole list, across all pages.
+	TotalCount int32 `json:"totalCount"`
+}
+
+type CourseEdge struct {
+	Cursor string  `json:"cursor"`
+	Node   *Course `json:"node"`
 }
 
 type Grade struct {
-	ID         string  `json:"id"`
-	StudentID  string  `json:"studentId"`
-	CourseID   string  `json:"courseId"`
 	Semester   string  `json:"semester"`
 	GradeType  string  `json:"gradeType"`
 	ItemID     string  `json:"itemId"`
@@ -37,19 +75,66 @@ type Grade struct {
 	Comments   *string `json:"comments,omitempty"`
 	GradedAt   string  `json:"gradedAt"`
 	UpdatedAt  string  `json:"updatedAt"`
+	// Local ID of the course, the courseId field returns its global ID
+	CourseID string `json:"courseId"`
+	// Local ID of the object in its microservice, the id field returns its global ID
+	ID string `json:"id"`
+	// Local ID of the student, the studentId field returns its global ID
+	StudentID string `json:"studentId"`
+}
+
+func (Grade) IsNode()            {}
+func (this Grade) GetID() string { return this.ID }
+
+type GradeConnection struct {
+	Edges    []*GradeEdge `json:"edges"`
+	PageInfo *PageInfo    `json:"pageInfo"`
+	// Number of grades in the whole list, across all pages.
+	TotalCount int32 `json:"totalCount"`
+}
+
+type GradeEdge struct {
+	Cursor string `json:"cursor"`
+	Node   *Grade `json:"node"`
+}
+
+// Narrows a list of grades to those matching every condition set.
+type GradeFilter struct {
+	GradeType *string `json:"gradeType,omitempty"`
+	ItemID    *string `json:"itemId,omitempty"`
+	StudentID *string `json:"studentId,omitempty"`
+	// Only numeric grade values at least this large. Non-numeric values never match a value range.
+	MinValue *float64 `json:"minValue,omitempty"`
+	// Only numeric grade values at most this large. Non-numeric values never match a value range.
+	MaxValue *float64 `json:"maxValue,omitempty"`
+	// Only grades given at or after this RFC 3339 time.
+	GradedAfter *string `json:"gradedAfter,omitempty"`
+	// Only grades given at or before this RFC 3339 time.
+	GradedBefore *string `json:"gradedBefore,omitempty"`
+}
+
+// Sorts a list of grades. Grades with equal keys keep their order by ID.
+type GradeOrder struct {
+	Field     GradeOrderField `json:"field"`
+	Direction OrderDirection  `json:"direction"`
 }
 
 type Homework struct {
-	ID          string `json:"id"`
-	CourseID    string `json:"courseId"`
 	Title       string `json:"title"`
 	Description string `json:"description"`
 	Workflow    string `json:"workflow"`
 	DueDate     string `json:"dueDate"`
 	CreatedAt   string `json:"createdAt"`
 	UpdatedAt   string `json:"updatedAt"`
+	// Local ID of the course, the courseId field returns its global ID
+	CourseID string `json:"courseId"`
+	// Local ID of the object in its microservice, the id field returns its global ID
+	ID string `json:"id"`
 }
 
+func (Homework) IsNode()            {}
+func (this Homework) GetID() string { return this.ID }
+
 type Mutation struct {
 }
 
@@ -100,39 +185,86 @@ type NewStudent struct {
 	PhoneNumber string `json:"phoneNumber"`
 }
 
+// Information about the page of a connection, as described by the Relay cursor connections specification.
+type PageInfo struct {
+	HasNextPage     bool `json:"hasNextPage"`
+	HasPreviousPage bool `json:"hasPreviousPage"`
+	// Cursor of the first edge of the page, null when the page is empty.
+	StartCursor *string `json:"startCursor,omitempty"`
+	// Cursor of the last edge of the page, null when the page is empty.
+	EndCursor *string `json:"endCursor,omitempty"`
+}
+
 type Query struct {
 }
 
 type Staff struct {
-	ID          string    `json:"id"`
-	FirstName   string    `json:"firstName"`
-	LastName    string    `json:"lastName"`
-	Email       string    `json:"email"`
-	PhoneNumber string    `json:"phoneNumber"`
-	Title       *string   `json:"title,omitempty"`
-	Office      *string   `json:"office,omitempty"`
-	CreatedAt   string    `json:"createdAt"`
-	UpdatedAt   string    `json:"updatedAt"`
-	Courses     []*Course `json:"courses"`
+	FirstName   string  `json:"firstName"`
+	LastName    string  `json:"lastName"`
+	Email       string  `json:"email"`
+	PhoneNumber string  `json:"phoneNumber"`
+	Title       *string `json:"title,omitempty"`
+	Office      *string `json:"office,omitempty"`
+	CreatedAt   string  `json:"createdAt"`
+	UpdatedAt   string  `json:"updatedAt"`
+	// Local ID of the object in its microservice, the id field returns its global ID
+	ID string `json:"id"`
 }
 
+func (Staff) IsNode()            {}
+func (this Staff) GetID() string { return this.ID }
+
 type Student struct {
-	ID          string    `json:"id"`
-	FirstName   string    `json:"firstName"`
-	LastName    string    `json:"lastName"`
-	Email       string    `json:"email"`
-	PhoneNumber string    `json:"phoneNumber"`
-	CreatedAt   string    `json:"createdAt"`
-	UpdatedAt   string    `json:"updatedAt"`
-	Courses     []*Course `json:"courses"`
+	FirstName   string `json:"firstName"`
+	LastName    string `json:"lastName"`
+	Email       string `json:"email"`
+	PhoneNumber string `json:"phoneNumber"`
+	CreatedAt   string `json:"createdAt"`
+	UpdatedAt   string `json:"updatedAt"`
+	// Local ID of the object in its microservice, the id field returns its global ID
+	ID string `json:"id"`
+}
+
+func (Student) IsNode()            {}
+func (this Student) GetID() string { return this.ID }
+
+type StudentConnection struct {
+	Edges    []*StudentEdge `json:"edges"`
+	PageInfo *PageInfo      `json:"pageInfo"`
+	// Number of students in the whole list, across all pages.
+	TotalCount int32 `json:"totalCount"`
+}
+
+type StudentEdge struct {
+	Cursor string `json:"cursor"`
+	// Null when the student could not be loaded, reported in errors.
+	Node *Student `json:"node,omitempty"`
 }
 
 type Submission struct {
-	ID          string `json:"id"`
-	HomeworkID  string `json:"homeworkId"`
-	StudentID   string `json:"studentId"`
-	SubmittedAt string `json:"submittedAt"`
-	UpdatedAt   string `json:"updatedAt"`
+	// Blob store reference of the submitted file.
+	FileRef *string `json:"fileRef,omitempty"`
+	// Name of the submitted file, as sent by the client.
+	Filename    *string `json:"filename,omitempty"`
+	ContentType *string `json:"contentType,omitempty"`
+	// Size of the submitted file in bytes.
+	Size *int `json:"size,omitempty"`
+	// Hex-encoded SHA-256 digest of the submitted file.
+	Checksum    *string `json:"checksum,omitempty"`
+	SubmittedAt string  `json:"submittedAt"`
+	UpdatedAt   string  `json:"updatedAt"`
+	// Local ID of the homework, the homeworkId field returns its global ID
+	HomeworkID string `json:"homeworkId"`
+	// Local ID of the object in its microservice, the id field returns its global ID
+	ID string `json:"id"`
+	// Local ID of the student, the studentId field returns its global ID
+	StudentID string `json:"studentId"`
+}
+
+func (Submission) IsNode()            {}
+func (this Submission) GetID() string { return this.ID }
+
+type Subscription struct {
 }
 
 type UpdateCourse struct {
@@ -161,3 +293,173 @@ type UpdateStudent struct {
 	Email       *string `json:"email,omitempty"`
 	PhoneNumber *string `json:"phoneNumber,omitempty"`
 }
+
+type GradeOrderField string
+
+const (
+	// Numeric values in numeric order, followed by the other values in alphabetical order.
+	GradeOrderFieldValue     GradeOrderField = "VALUE"
+	GradeOrderFieldGradedAt  GradeOrderField = "GRADED_AT"
+	GradeOrderFieldStudentID GradeOrderField = "STUDENT_ID"
+)
+
+var AllGradeOrderField = []GradeOrderField{
+	GradeOrderFieldValue,
+	GradeOrderFieldGradedAt,
+	GradeOrderFieldStudentID,
+}
+
+func (e GradeOrderField) IsValid() bool {
+	switch e {
+	case GradeOrderFieldValue, GradeOrderFieldGradedAt, GradeOrderFieldStudentID:
+		return true
+	}
+	return false
+}
+
+func (e GradeOrderField) String() string {
+	return string(e)
+}
+
+func (e *GradeOrderField) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = GradeOrderField(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid GradeOrderField", str)
+	}
+	return nil
+}
+
+func (e GradeOrderField) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *GradeOrderField) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e GradeOrderField) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type OrderDirection string
+
+const (
+	OrderDirectionAsc  OrderDirection = "ASC"
+	OrderDirectionDesc OrderDirection = "DESC"
+)
+
+var AllOrderDirection = []OrderDirection{
+	OrderDirectionAsc,
+	OrderDirectionDesc,
+}
+
+func (e OrderDirection) IsValid() bool {
+	switch e {
+	case OrderDirectionAsc, OrderDirectionDesc:
+		return true
+	}
+	return false
+}
+
+func (e OrderDirection) String() string {
+	return string(e)
+}
+
+func (e *OrderDirection) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = OrderDirection(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid OrderDirection", str)
+	}
+	return nil
+}
+
+func (e OrderDirection) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *OrderDirection) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e OrderDirection) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type Role string
+
+const (
+	RoleStudent Role = "STUDENT"
+	RoleStaff   Role = "STAFF"
+	RoleAdmin   Role = "ADMIN"
+)
+
+var AllRole = []Role{
+	RoleStudent,
+	RoleStaff,
+	RoleAdmin,
+}
+
+func (e Role) IsValid() bool {
+	switch e {
+	case RoleStudent, RoleStaff, RoleAdmin:
+		return true
+	}
+	return false
+}
+
+func (e Role) String() string {
+	return string(e)
+}
+
+func (e *Role) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = Role(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid Role", str)
+	}
+	return nil
+}
+
+func (e Role) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *Role) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e Role) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
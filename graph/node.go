@@ -0,0 +1,329 @@
+package graph
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/BetterGR/api-gateway/graph/model"
+)
+
+// Objects implementing the Node interface are identified by a global ID, the name of their
+// type and their local ID in the microservice that owns them, or the composite key they are
+// looked up by for grades and announcements, wrapped in URL-safe base64 so
+// that clients treat it as opaque and can put it in links. The models keep the local ID,
+// which is what the microservices and the loaders expect, and the id resolvers encode it.
+//
+// Arguments and input fields marked with @nodeId accept either form, so that clients written
+// before global IDs existed keep working.
+
+// Names of the types implementing the Node interface
+const (
+	nodeTypeStudent      = "Student"
+	nodeTypeStaff        = "Staff"
+	nodeTypeCourse       = "Course"
+	nodeTypeGrade        = "Grade"
+	nodeTypeAnnouncement = "Announcement"
+	nodeTypeHomework     = "Homework"
+	nodeTypeSubmission   = "Submission"
+)
+
+// nodeTypes lists the types whose IDs can be decoded
+var nodeTypes = map[string]bool{
+	nodeTypeStudent:      true,
+	nodeTypeStaff:        true,
+	nodeTypeCourse:       true,
+	nodeTypeGrade:        true,
+	nodeTypeAnnouncement: true,
+	nodeTypeHomework:     true,
+	nodeTypeSubmission:   true,
+}
+
+// keySeparator separates the parts of the composite local IDs of grades and announcements
+const keySeparator = "/"
+
+// gradeKey identifies a grade. The grades microservice has no lookup by grade ID, so grades
+// are found from the course, semester, student, type and item they were given for, and the
+// local ID encoded in their global ID is this composite key. GradeID is the ID given by the
+// microservice, needed to update the grade.
+type gradeKey struct {
+	CourseID  string
+	Semester  string
+	StudentID string
+	GradeType string
+	ItemID    string
+	GradeID   string
+}
+
+// gradeKeyOf returns the key of a grade
+func gradeKeyOf(g *model.Grade) gradeKey {
+	return gradeKey{
+		CourseID:  g.CourseID,
+		Semester:  g.Semester,
+		StudentID: g.StudentID,
+		GradeType: g.GradeType,
+		ItemID:    g.ItemID,
+		GradeID:   g.ID,
+	}
+}
+
+// identify sets the fields of g that make up the key, so that g has the ID of the grade
+// identified by the key
+func (k gradeKey) identify(g *model.Grade) {
+	g.CourseID = k.CourseID
+	g.Semester = k.Semester
+	g.StudentID = k.StudentID
+	g.GradeType = k.GradeType
+	g.ItemID = k.ItemID
+	g.ID = k.GradeID
+}
+
+// String encodes the key as the local ID of the grade
+func (k gradeKey) String() string {
+	return joinKey(k.CourseID, k.Semester, k.StudentID, k.GradeType, k.ItemID, k.GradeID)
+}
+
+// parseGradeKey decodes the local ID of a grade. ok is false for the grade IDs of the
+// microservice used before global IDs.
+func parseGradeKey(id string) (key gradeKey, ok bool) {
+	parts, ok := splitKey(id, 6)
+	if !ok {
+		return gradeKey{}, false
+	}
+
+	return gradeKey{
+		CourseID:  parts[0],
+		Semester:  parts[1],
+		StudentID: parts[2],
+		GradeType: parts[3],
+		ItemID:    parts[4],
+		GradeID:   parts[5],
+	}, true
+}
+
+// resolveGradeKey returns the key of the grade identified by id. The IDs given by the grades
+// microservice don't carry the key, which must then be given by the deprecated arguments of
+// deleteGrade. Arguments given along with the id of a Grade must match it.
+func resolveGradeKey(ctx context.Context, id string, courseID, semester, studentID, gradeType, itemID *string) (gradeKey, error) {
+	if key, ok := parseGradeKey(id); ok {
+		for _, arg := range []struct {
+			value *string
+			want  string
+		}{
+			{courseID, key.CourseID},
+			{semester, key.Semester},
+			{studentID, key.StudentID},
+			{gradeType, key.GradeType},
+			{itemID, key.ItemID},
+		} {
+			if arg.value != nil && *arg.value != arg.want {
+				return gradeKey{}, badUserInputError(ctx, "the arguments do not match the grade id, pass the id alone")
+			}
+		}
+		return key, nil
+	}
+
+	if courseID == nil || semester == nil || studentID == nil || gradeType == nil || itemID == nil {
+		return gradeKey{}, badUserInputError(ctx, "courseId, semester, studentId, gradeType and itemId are required with grade IDs given by the grades microservice")
+	}
+
+	return gradeKey{
+		CourseID:  *courseID,
+		Semester:  *semester,
+		StudentID: *studentID,
+		GradeType: *gradeType,
+		ItemID:    *itemID,
+		GradeID:   id,
+	}, nil
+}
+
+// announcementKey identifies an announcement. The courses microservice only lists the
+// announcements of a course, so the local ID of an announcement carries its course.
+type announcementKey struct {
+	CourseID       string
+	AnnouncementID string
+}
+
+// String encodes the key as the local ID of the announcement
+func (k announcementKey) String() string {
+	return joinKey(k.CourseID, k.AnnouncementID)
+}
+
+// parseAnnouncementKey decodes the local ID of an announcement. ok is false for the
+// announcement IDs of the microservice used before global IDs.
+func parseAnnouncementKey(id string) (key announcementKey, ok bool) {
+	parts, ok := splitKey(id, 2)
+	if !ok {
+		return announcementKey{}, false
+	}
+
+	return announcementKey{CourseID: parts[0], AnnouncementID: parts[1]}, true
+}
+
+// joinKey encodes the parts of a composite key, escaping the separator within them
+func joinKey(parts ...string) string {
+	escaped := make([]string, len(parts))
+	for i, part := range parts {
+		escaped[i] = url.PathEscape(part)
+	}
+	return strings.Join(escaped, keySeparator)
+}
+
+// splitKey decodes a composite key made of n parts
+func splitKey(key string, n int) ([]string, bool) {
+	parts := strings.Split(key, keySeparator)
+	if len(parts) != n {
+		return nil, false
+	}
+
+	for i, part := range parts {
+		unescaped, err := url.PathUnescape(part)
+		if err != nil {
+			return nil, false
+		}
+		parts[i] = unescaped
+	}
+
+	return parts, true
+}
+
+// globalID returns the global ID of the object of type typeName with the given local ID
+func globalID(typeName, localID string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(typeName + ":" + localID))
+}
+
+// parseGlobalID returns the type and local ID encoded in a global ID. ok is false when id is
+// not a global ID, as is the case for local IDs. Only the exact encoding made by globalID is
+// accepted, so that strings that merely decode as base64 are not taken for global IDs.
+func parseGlobalID(id string) (typeName, localID string, ok bool) {
+	raw, err := base64.RawURLEncoding.Strict().DecodeString(id)
+	if err != nil {
+		return "", "", false
+	}
+
+	typeName, localID, found := strings.Cut(string(raw), ":")
+	if !found || !nodeTypes[typeName] || localID == "" {
+		return "", "", false
+	}
+
+	return typeName, localID, true
+}
+
+// localID returns the local ID of an object of type typeName from either its global ID or its
+// local ID. Global IDs of objects of another type are rejected. Both forms share the argument,
+// so a local ID that is itself the encoding of "<Type>:<id>" for one of the node types is read
+// as a global ID; the numeric and UUID IDs of the microservices never are.
+func localID(ctx context.Context, typeName, id string) (string, error) {
+	idType, local, ok := parseGlobalID(id)
+	if !ok {
+		return id, nil
+	}
+	if idType != typeName {
+		return "", badUserInputError(ctx, fmt.Sprintf("ID %q belongs to a %s, expected a %s", id, idType, typeName))
+	}
+
+	return local, nil
+}
+
+// nodeIDDirective implements @nodeId: it replaces the global ID given to an argument or an
+// input field with the local ID the resolvers expect
+func nodeIDDirective(ctx context.Context, obj any, next graphql.Resolver, typeName string) (any, error) {
+	value, err := next(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	switch id := value.(type) {
+	case string:
+		return localID(ctx, typeName, id)
+	case *string:
+		if id == nil {
+			return id, nil
+		}
+		local, err := localID(ctx, typeName, *id)
+		if err != nil {
+			return nil, err
+		}
+		return &local, nil
+	default:
+		return value, nil
+	}
+}
+
+// fetchNode returns the object identified by a global ID, nil when it does not exist. Objects
+// are fetched through the root field of their type, so that the same permissions apply.
+func (r *Resolver) fetchNode(ctx context.Context, id string) (model.Node, error) {
+	typeName, local, ok := parseGlobalID(id)
+	if !ok {
+		return nil, badUserInputError(ctx, fmt.Sprintf("invalid node ID %q", id))
+	}
+
+	query := &queryResolver{r}
+
+	var node model.Node
+	var err error
+	switch typeName {
+	case nodeTypeStudent:
+		node, err = asNode(query.Student(ctx, local))
+	case nodeTypeStaff:
+		node, err = asNode(query.Staff(ctx, local))
+	case nodeTypeCourse:
+		node, err = asNode(query.Course(ctx, local))
+	case nodeTypeGrade:
+		node, err = asNode(query.Grade(ctx, local))
+	case nodeTypeAnnouncement:
+		node, err = asNode(query.Announcement(ctx, local))
+	case nodeTypeHomework:
+		node, err = asNode(query.Homework(ctx, local))
+	case nodeTypeSubmission:
+		node, err = asNode(query.Submission(ctx, local))
+	}
+
+	// Objects that no longer exist resolve to null, as clients refetching them expect
+	if isNotFound(err) {
+		return nil, nil
+	}
+
+	return node, err
+}
+
+// fetchNodes returns the objects identified by global IDs, nil for those that do not exist or
+// failed to load. Objects are fetched concurrently so that the loaders batch their lookups.
+func (r *Resolver) fetchNodes(ctx context.Context, ids []string) ([]model.Node, error) {
+	if len(ids) > maxPageSize {
+		return nil, badUserInputError(ctx, fmt.Sprintf("at most %d nodes may be fetched at once", maxPageSize))
+	}
+
+	nodes := make([]model.Node, len(ids))
+	errs := make([]error, len(ids))
+
+	var wg sync.WaitGroup
+	for i, id := range ids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			nodes[i], errs[i] = r.fetchNode(ctx, id)
+		}()
+	}
+	wg.Wait()
+
+	addItemErrors(ctx, errs)
+
+	return nodes, nil
+}
+
+// asNode returns the object returned by a root resolver as a Node, keeping a missing object
+// a nil interface
+func asNode[T any, P interface {
+	*T
+	model.Node
+}](obj P, err error) (model.Node, error) {
+	if err != nil || obj == nil {
+		return nil, err
+	}
+	return obj, nil
+}
@@ -0,0 +1,333 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/BetterGR/api-gateway/graph/model"
+)
+
+func TestParseGlobalID(t *testing.T) {
+	tests := []struct {
+		name      string
+		id        string
+		wantType  string
+		wantLocal string
+		wantOK    bool
+	}{
+		{name: "global ID", id: globalID(nodeTypeCourse, "c1"), wantType: nodeTypeCourse, wantLocal: "c1", wantOK: true},
+		{name: "composite key", id: globalID(nodeTypeAnnouncement, "c1/a1"), wantType: nodeTypeAnnouncement, wantLocal: "c1/a1", wantOK: true},
+		{name: "numeric local ID", id: "42"},
+		{name: "UUID local ID", id: "3f6c2a9e-8d1b-4c5e-9a7f-2b4d6e8f0a1c"},
+		{name: "unknown type", id: "VGVhY2hlcjp0MQ"},           // Teacher:t1
+		{name: "empty local ID", id: "Q291cnNlOg"},             // Course:
+		{name: "no separator", id: "Q291cnNl"},                 // Course
+		{name: "non-canonical encoding", id: "Q291cnNlOmMxMh"}, // Course:c12 with padding bits set
+		{name: "standard base64", id: "Q291cnNlOmMx+g"},
+		{name: "empty", id: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			typeName, local, ok := parseGlobalID(tt.id)
+			if typeName != tt.wantType || local != tt.wantLocal || ok != tt.wantOK {
+				t.Errorf("parseGlobalID(%q) = %q, %q, %v, want %q, %q, %v",
+					tt.id, typeName, local, ok, tt.wantType, tt.wantLocal, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestLocalID(t *testing.T) {
+	tests := []struct {
+		name     string
+		id       string
+		want     string
+		wantCode string
+	}{
+		{name: "global ID of the type", id: globalID(nodeTypeStudent, "s1"), want: "s1"},
+		{name: "local ID", id: "s1", want: "s1"},
+		{name: "global ID of another type", id: globalID(nodeTypeCourse, "s1"), wantCode: ErrCodeBadUserInput},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := localID(context.Background(), nodeTypeStudent, tt.id)
+			if code := errorCode(err); code != tt.wantCode {
+				t.Fatalf("got error %v, want code %q", err, tt.wantCode)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNodeArgumentsAcceptBothIDs(t *testing.T) {
+	r := newFakeSchool().resolver()
+
+	for _, id := range []string{"s1", globalID(nodeTypeStudent, "s1")} {
+		res := executeAs(t, r, "s1", model.RoleStudent, `{ student(id: "`+id+`") { id } }`)
+		if len(res.Errors) > 0 {
+			t.Errorf("student(%q): unexpected errors %v", id, res.Errors)
+		}
+	}
+
+	res := executeAs(t, r, "s1", model.RoleStudent, `{ student(id: "`+globalID(nodeTypeCourse, "c1")+`") { id } }`)
+	if codes := res.errorCodes(); !slices.Equal(codes, []string{ErrCodeBadUserInput}) {
+		t.Errorf("student(course ID): got error codes %v, want BAD_USER_INPUT", codes)
+	}
+}
+
+func TestNode(t *testing.T) {
+	r := newFakeSchool().resolver()
+
+	tests := []struct {
+		name      string
+		id        string
+		wantType  string
+		wantCodes []string
+	}{
+		{name: "student", id: globalID(nodeTypeStudent, "s1"), wantType: nodeTypeStudent},
+		{name: "course", id: globalID(nodeTypeCourse, "c1"), wantType: nodeTypeCourse},
+		{name: "missing object", id: globalID(nodeTypeCourse, "c9")},
+		{name: "local ID", id: "s1", wantCodes: []string{ErrCodeBadUserInput}},
+		{name: "unknown type", id: "VGVhY2hlcjp0MQ", wantCodes: []string{ErrCodeBadUserInput}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := executeAs(t, r, "s1", model.RoleStudent, `{ node(id: "`+tt.id+`") { __typename id } }`)
+			if codes := res.errorCodes(); !slices.Equal(codes, tt.wantCodes) {
+				t.Fatalf("got error codes %v, want %v", codes, tt.wantCodes)
+			}
+
+			var data struct {
+				Node *struct {
+					Typename string `json:"__typename"`
+					ID       string `json:"id"`
+				} `json:"node"`
+			}
+			if err := json.Unmarshal(res.Data, &data); err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantType == "" {
+				if data.Node != nil {
+					t.Errorf("got %+v, want null", data.Node)
+				}
+				return
+			}
+			if data.Node == nil || data.Node.Typename != tt.wantType || data.Node.ID != tt.id {
+				t.Errorf("got %+v, want the %s %q", data.Node, tt.wantType, tt.id)
+			}
+		})
+	}
+}
+
+func TestNodesWithMixedIDs(t *testing.T) {
+	r := newFakeSchool().resolver()
+
+	student := globalID(nodeTypeStudent, "s1")
+	course := globalID(nodeTypeCourse, "c1")
+	missing := globalID(nodeTypeCourse, "c9")
+	foreign := globalID(nodeTypeStudent, "s3")
+
+	res := executeAs(t, r, "s1", model.RoleStudent,
+		`{ nodes(ids: ["`+student+`", "s1", "`+missing+`", "`+course+`", "`+foreign+`"]) { __typename id } }`)
+
+	var data struct {
+		Nodes []*struct {
+			Typename string `json:"__typename"`
+			ID       string `json:"id"`
+		} `json:"nodes"`
+	}
+	if err := json.Unmarshal(res.Data, &data); err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Nodes) != 5 {
+		t.Fatalf("got %d nodes, want 5", len(data.Nodes))
+	}
+	if data.Nodes[0] == nil || data.Nodes[0].ID != student {
+		t.Errorf("nodes[0]: got %+v, want the student", data.Nodes[0])
+	}
+	if data.Nodes[3] == nil || data.Nodes[3].ID != course {
+		t.Errorf("nodes[3]: got %+v, want the course", data.Nodes[3])
+	}
+	for _, i := range []int{1, 2, 4} {
+		if data.Nodes[i] != nil {
+			t.Errorf("nodes[%d]: got %+v, want null", i, data.Nodes[i])
+		}
+	}
+
+	// The local ID and the student the caller may not read are reported at their index, the
+	// missing course is only null
+	want := map[float64]string{1: ErrCodeBadUserInput, 4: ErrCodeForbidden}
+	if len(res.Errors) != len(want) {
+		t.Fatalf("got errors %v, want one for nodes 1 and 4", res.Errors)
+	}
+	for _, err := range res.Errors {
+		if len(err.Path) != 2 || err.Path[0] != "nodes" {
+			t.Errorf("got error %q at path %v, want a path under nodes", err.Message, err.Path)
+			continue
+		}
+		index, _ := err.Path[1].(float64)
+		if code := err.Extensions["code"]; code != want[index] {
+			t.Errorf("nodes[%v]: got code %v, want %s", index, code, want[index])
+		}
+	}
+}
+
+func TestResolveGradeKey(t *testing.T) {
+	key := gradeKey{CourseID: "c1", Semester: "2025-spring", StudentID: "s1", GradeType: "exam", ItemID: "final", GradeID: "g1"}
+	legacy := key
+	legacy.GradeID = "g9"
+
+	tests := []struct {
+		name      string
+		id        string
+		courseID  *string
+		semester  *string
+		studentID *string
+		gradeType *string
+		itemID    *string
+		want      gradeKey
+		wantCode  string
+	}{
+		{name: "grade id alone", id: key.String(), want: key},
+		{
+			name: "grade id with matching arguments", id: key.String(),
+			courseID: ptr("c1"), semester: ptr("2025-spring"), studentID: ptr("s1"), gradeType: ptr("exam"), itemID: ptr("final"),
+			want: key,
+		},
+		{name: "grade id with another course", id: key.String(), courseID: ptr("c2"), wantCode: ErrCodeBadUserInput},
+		{name: "grade id with another item", id: key.String(), itemID: ptr("midterm"), wantCode: ErrCodeBadUserInput},
+		{
+			name: "microservice id with every argument", id: "g9",
+			courseID: ptr("c1"), semester: ptr("2025-spring"), studentID: ptr("s1"), gradeType: ptr("exam"), itemID: ptr("final"),
+			want: legacy,
+		},
+		{
+			name: "microservice id without the item", id: "g9",
+			courseID: ptr("c1"), semester: ptr("2025-spring"), studentID: ptr("s1"), gradeType: ptr("exam"),
+			wantCode: ErrCodeBadUserInput,
+		},
+		{name: "microservice id alone", id: "g9", wantCode: ErrCodeBadUserInput},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveGradeKey(context.Background(), tt.id, tt.courseID, tt.semester, tt.studentID, tt.gradeType, tt.itemID)
+			if code := errorCode(err); code != tt.wantCode {
+				t.Fatalf("got error %v, want code %q", err, tt.wantCode)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGradeByID(t *testing.T) {
+	r := newFakeSchool().resolver()
+
+	g1 := gradeKey{CourseID: "c1", Semester: "2025-spring", StudentID: "s1", GradeType: "exam", ItemID: "final", GradeID: "g1"}
+	midterm := g1
+	midterm.ItemID = "midterm"
+
+	tests := []struct {
+		name      string
+		subject   string
+		role      model.Role
+		id        string
+		wantValue string
+		wantCodes []string
+	}{
+		{name: "own grade", subject: "s1", role: model.RoleStudent, id: globalID(nodeTypeGrade, g1.String()), wantValue: "80"},
+		{name: "grade in a taught course", subject: "t1", role: model.RoleStaff, id: globalID(nodeTypeGrade, g1.String()), wantValue: "80"},
+		{name: "missing grade", subject: "s1", role: model.RoleStudent, id: globalID(nodeTypeGrade, midterm.String()), wantCodes: []string{ErrCodeNotFound}},
+		{name: "grade of a classmate", subject: "s2", role: model.RoleStudent, id: globalID(nodeTypeGrade, g1.String()), wantCodes: []string{ErrCodeForbidden}},
+		{name: "grade outside the taught courses", subject: "t2", role: model.RoleStaff, id: globalID(nodeTypeGrade, g1.String()), wantCodes: []string{ErrCodeForbidden}},
+		{name: "microservice id", subject: "s1", role: model.RoleStudent, id: "g1", wantCodes: []string{ErrCodeBadUserInput}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := executeAs(t, r, tt.subject, tt.role, `{ grade(id: "`+tt.id+`") { id gradeValue } }`)
+			if codes := res.errorCodes(); !slices.Equal(codes, tt.wantCodes) {
+				t.Fatalf("got error codes %v, want %v", codes, tt.wantCodes)
+			}
+
+			var data struct {
+				Grade *struct {
+					ID         string `json:"id"`
+					GradeValue string `json:"gradeValue"`
+				} `json:"grade"`
+			}
+			if err := json.Unmarshal(res.Data, &data); err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantValue == "" {
+				if data.Grade != nil {
+					t.Errorf("got %+v, want null", data.Grade)
+				}
+				return
+			}
+			if data.Grade == nil || data.Grade.ID != tt.id || data.Grade.GradeValue != tt.wantValue {
+				t.Errorf("got %+v, want the grade %q of %s", data.Grade, tt.id, tt.wantValue)
+			}
+		})
+	}
+}
+
+func TestAnnouncementByID(t *testing.T) {
+	r := newFakeSchool().resolver()
+
+	a1 := announcementKey{CourseID: "c1", AnnouncementID: "a1"}
+	a9 := announcementKey{CourseID: "c1", AnnouncementID: "a9"}
+
+	tests := []struct {
+		name      string
+		subject   string
+		role      model.Role
+		id        string
+		wantTitle string
+		wantCodes []string
+	}{
+		{name: "announcement of a taken course", subject: "s1", role: model.RoleStudent, id: globalID(nodeTypeAnnouncement, a1.String()), wantTitle: "Welcome to c1"},
+		{name: "announcement of a taught course", subject: "t1", role: model.RoleStaff, id: globalID(nodeTypeAnnouncement, a1.String()), wantTitle: "Welcome to c1"},
+		{name: "missing announcement", subject: "s1", role: model.RoleStudent, id: globalID(nodeTypeAnnouncement, a9.String()), wantCodes: []string{ErrCodeNotFound}},
+		{name: "announcement outside the taken courses", subject: "s3", role: model.RoleStudent, id: globalID(nodeTypeAnnouncement, a1.String()), wantCodes: []string{ErrCodeForbidden}},
+		{name: "announcement outside the taught courses", subject: "t2", role: model.RoleStaff, id: globalID(nodeTypeAnnouncement, a1.String()), wantCodes: []string{ErrCodeForbidden}},
+		{name: "microservice id", subject: "s1", role: model.RoleStudent, id: "a1", wantCodes: []string{ErrCodeBadUserInput}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := executeAs(t, r, tt.subject, tt.role, `{ announcement(id: "`+tt.id+`") { id title } }`)
+			if codes := res.errorCodes(); !slices.Equal(codes, tt.wantCodes) {
+				t.Fatalf("got error codes %v, want %v", codes, tt.wantCodes)
+			}
+
+			var data struct {
+				Announcement *struct {
+					ID    string `json:"id"`
+					Title string `json:"title"`
+				} `json:"announcement"`
+			}
+			if err := json.Unmarshal(res.Data, &data); err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantTitle == "" {
+				if data.Announcement != nil {
+					t.Errorf("got %+v, want null", data.Announcement)
+				}
+				return
+			}
+			if data.Announcement == nil || data.Announcement.ID != tt.id || data.Announcement.Title != tt.wantTitle {
+				t.Errorf("got %+v, want the announcement %q titled %q", data.Announcement, tt.id, tt.wantTitle)
+			}
+		})
+	}
+}
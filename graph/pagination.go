@@ -0,0 +1,195 @@
+package graph
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/BetterGR/api-gateway/graph/model"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// The microservices return whole lists, so connections are paginated in the gateway.
+// Cursors encode the offset of an edge in the list, wrapped in base64 so that clients
+// treat them as opaque.
+
+// maxPageSize bounds the number of edges of a page, and is the size of the pages requested
+// without first or last
+const maxPageSize = 100
+
+// cursorPrefix tells the offsets encoded in cursors apart from other strings
+const cursorPrefix = "offset:"
+
+// pageArgs are the Relay pagination arguments of a connection field
+type pageArgs struct {
+	first  *int32
+	after  *string
+	last   *int32
+	before *string
+}
+
+// page is the range [start, end) of a list covered by a page of a connection
+type page struct {
+	start int
+	end   int
+	info  *model.PageInfo
+}
+
+// paginate selects the range of a list of total items requested by args, following the
+// Relay cursor connections specification: the list is first narrowed to the items between
+// the after and before cursors, then cut to its first or last items
+func paginate(ctx context.Context, total int, args pageArgs) (page, error) {
+	start, end := 0, total
+
+	if args.after != nil {
+		after, err := decodeCursor(ctx, *args.after)
+		if err != nil {
+			return page{}, err
+		}
+		start = min(max(after+1, 0), total)
+	}
+	if args.before != nil {
+		before, err := decodeCursor(ctx, *args.before)
+		if err != nil {
+			return page{}, err
+		}
+		end = max(min(before, total), start)
+	}
+
+	switch {
+	case args.first != nil && args.last != nil:
+		return page{}, badUserInputError(ctx, "first and last cannot be used together")
+	case args.first != nil:
+		if *args.first < 0 || *args.first > maxPageSize {
+			return page{}, badUserInputError(ctx, fmt.Sprintf("first must be between 0 and %d", maxPageSize))
+		}
+		end = min(end, start+int(*args.first))
+	case args.last != nil:
+		if *args.last < 0 || *args.last > maxPageSize {
+			return page{}, badUserInputError(ctx, fmt.Sprintf("last must be between 0 and %d", maxPageSize))
+		}
+		start = max(start, end-int(*args.last))
+	default:
+		end = min(end, start+maxPageSize)
+	}
+
+	info := &model.PageInfo{
+		HasPreviousPage: start > 0,
+		HasNextPage:     end < total,
+	}
+	if start < end {
+		startCursor, endCursor := encodeCursor(start), encodeCursor(end-1)
+		info.StartCursor, info.EndCursor = &startCursor, &endCursor
+	}
+
+	return page{start: start, end: end, info: info}, nil
+}
+
+// pageEdges builds the edges of a page from its nodes, the items of the list from p.start
+// to p.end
+func pageEdges[T, E any](p page, nodes []T, edge func(cursor string, node T) E) []E {
+	edges := make([]E, len(nodes))
+	for i, node := range nodes {
+		edges[i] = edge(encodeCursor(p.start+i), node)
+	}
+	return edges
+}
+
+// encodeCursor returns the cursor of the item at offset
+func encodeCursor(offset int) string {
+	return base64.StdEncoding.EncodeToString([]byte(cursorPrefix + strconv.Itoa(offset)))
+}
+
+// decodeCursor returns the offset encoded in a cursor
+func decodeCursor(ctx context.Context, cursor string) (int, error) {
+	raw, err := base64.StdEncoding.DecodeString(cursor)
+	if err == nil {
+		if offset, ok := strings.CutPrefix(string(raw), cursorPrefix); ok {
+			if n, err := strconv.Atoi(offset); err == nil && n >= 0 {
+				return n, nil
+			}
+		}
+	}
+
+	return 0, badUserInputError(ctx, fmt.Sprintf("invalid cursor %q", cursor))
+}
+
+// addEdgeErrors reports the failed lookups of the nodes of a page on the path of their edge
+func addEdgeErrors(ctx context.Context, errs []error) {
+	fieldPath := graphql.GetPath(ctx)
+
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+
+		path := make(ast.Path, len(fieldPath), len(fieldPath)+3)
+		copy(path, fieldPath)
+		path = append(path, ast.PathName("edges"), ast.PathIndex(i), ast.PathName("node"))
+		graphql.AddError(ctx, errorOnPath(path, err))
+	}
+}
+
+// newCourseConnection returns the requested page of a list of courses
+func newCourseConnection(ctx context.Context, courses []*model.Course, args pageArgs) (*model.CourseConnection, error) {
+	p, err := paginate(ctx, len(courses), args)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.CourseConnection{
+		Edges: pageEdges(p, courses[p.start:p.end], func(cursor string, node *model.Course) *model.CourseEdge {
+			return &model.CourseEdge{Cursor: cursor, Node: node}
+		}),
+		PageInfo:   p.info,
+		TotalCount: int32(len(courses)),
+	}, nil
+}
+
+// newGradeConnection returns the requested page of a list of grades
+func newGradeConnection(ctx context.Context, grades []*model.Grade, args pageArgs) (*model.GradeConnection, error) {
+	p, err := paginate(ctx, len(grades), args)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.GradeConnection{
+		Edges: pageEdges(p, grades[p.start:p.end], func(cursor string, node *model.Grade) *model.GradeEdge {
+			return &model.GradeEdge{Cursor: cursor, Node: node}
+		}),
+		PageInfo:   p.info,
+		TotalCount: int32(len(grades)),
+	}, nil
+}
+
+// newAnnouncementConnection returns the requested page of a list of announcements
+func newAnnouncementConnection(ctx context.Context, announcements []*model.Announcement, args pageArgs) (*model.AnnouncementConnection, error) {
+	p, err := paginate(ctx, len(announcements), args)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.AnnouncementConnection{
+		Edges: pageEdges(p, announcements[p.start:p.end], func(cursor string, node *model.Announcement) *model.AnnouncementEdge {
+			return &model.AnnouncementEdge{Cursor: cursor, Node: node}
+		}),
+		PageInfo:   p.info,
+		TotalCount: int32(len(announcements)),
+	}, nil
+}
+
+// pageSizeEstimate is the number of edges a connection field is expected to return, used to
+// weigh its complexity
+func pageSizeEstimate(first, last *int32) int {
+	switch {
+	case first != nil:
+		return min(max(int(*first), 0), maxPageSize)
+	case last != nil:
+		return min(max(int(*last), 0), maxPageSize)
+	default:
+		return maxPageSize
+	}
+}
@@ -0,0 +1,97 @@
+package graph
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+)
+
+func TestPaginate(t *testing.T) {
+	cursor := func(offset int) *string { return ptr(encodeCursor(offset)) }
+	tampered := func(raw string) *string { return ptr(base64.StdEncoding.EncodeToString([]byte(raw))) }
+
+	tests := []struct {
+		name         string
+		args         pageArgs
+		wantStart    int
+		wantEnd      int
+		wantPrevious bool
+		wantNext     bool
+		wantCode     string
+	}{
+		{name: "whole list", args: pageArgs{}, wantStart: 0, wantEnd: 10},
+		{name: "first", args: pageArgs{first: ptr[int32](3)}, wantStart: 0, wantEnd: 3, wantNext: true},
+		{name: "first after", args: pageArgs{first: ptr[int32](3), after: cursor(2)}, wantStart: 3, wantEnd: 6, wantPrevious: true, wantNext: true},
+		{name: "first after, past the end", args: pageArgs{first: ptr[int32](5), after: cursor(7)}, wantStart: 8, wantEnd: 10, wantPrevious: true},
+		{name: "last", args: pageArgs{last: ptr[int32](3)}, wantStart: 7, wantEnd: 10, wantPrevious: true},
+		{name: "last before", args: pageArgs{last: ptr[int32](2), before: cursor(5)}, wantStart: 3, wantEnd: 5, wantPrevious: true, wantNext: true},
+		{name: "last before, past the start", args: pageArgs{last: ptr[int32](5), before: cursor(2)}, wantStart: 0, wantEnd: 2, wantNext: true},
+		{name: "after and before", args: pageArgs{after: cursor(2), before: cursor(6)}, wantStart: 3, wantEnd: 6, wantPrevious: true, wantNext: true},
+		{name: "first zero", args: pageArgs{first: ptr[int32](0)}, wantStart: 0, wantEnd: 0, wantNext: true},
+		{name: "after the last item", args: pageArgs{after: cursor(20)}, wantStart: 10, wantEnd: 10, wantPrevious: true},
+		{name: "first and last", args: pageArgs{first: ptr[int32](3), last: ptr[int32](3)}, wantCode: ErrCodeBadUserInput},
+		{name: "negative first", args: pageArgs{first: ptr[int32](-1)}, wantCode: ErrCodeBadUserInput},
+		{name: "negative last", args: pageArgs{last: ptr[int32](-1)}, wantCode: ErrCodeBadUserInput},
+		{name: "first over the page size", args: pageArgs{first: ptr[int32](maxPageSize + 1)}, wantCode: ErrCodeBadUserInput},
+		{name: "invalid cursor", args: pageArgs{after: ptr("not a cursor")}, wantCode: ErrCodeBadUserInput},
+		{name: "cursor without the prefix", args: pageArgs{after: tampered("3")}, wantCode: ErrCodeBadUserInput},
+		{name: "cursor with a negative offset", args: pageArgs{before: tampered(cursorPrefix + "-1")}, wantCode: ErrCodeBadUserInput},
+		{name: "cursor with a non-numeric offset", args: pageArgs{before: tampered(cursorPrefix + "x")}, wantCode: ErrCodeBadUserInput},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := paginate(context.Background(), 10, tt.args)
+			if code := errorCode(err); code != tt.wantCode {
+				t.Fatalf("got error %v, want code %q", err, tt.wantCode)
+			}
+			if err != nil {
+				return
+			}
+
+			if p.start != tt.wantStart || p.end != tt.wantEnd {
+				t.Errorf("got items [%d, %d), want [%d, %d)", p.start, p.end, tt.wantStart, tt.wantEnd)
+			}
+			if p.info.HasPreviousPage != tt.wantPrevious || p.info.HasNextPage != tt.wantNext {
+				t.Errorf("got previous %v and next %v, want %v and %v",
+					p.info.HasPreviousPage, p.info.HasNextPage, tt.wantPrevious, tt.wantNext)
+			}
+
+			// The cursors of the page lead to its first and last items
+			if p.start == p.end {
+				if p.info.StartCursor != nil || p.info.EndCursor != nil {
+					t.Errorf("got cursors for an empty page")
+				}
+				return
+			}
+			for cursor, want := range map[*string]int{p.info.StartCursor: p.start, p.info.EndCursor: p.end - 1} {
+				if offset, err := decodeCursor(context.Background(), *cursor); err != nil || offset != want {
+					t.Errorf("cursor %q decodes to %d, %v, want %d", *cursor, offset, err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPageSizeEstimate(t *testing.T) {
+	tests := []struct {
+		name  string
+		first *int32
+		last  *int32
+		want  int
+	}{
+		{name: "default", want: maxPageSize},
+		{name: "first", first: ptr[int32](5), want: 5},
+		{name: "last", last: ptr[int32](7), want: 7},
+		{name: "negative", first: ptr[int32](-3), want: 0},
+		{name: "over the page size", last: ptr[int32](maxPageSize * 2), want: maxPageSize},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pageSizeEstimate(tt.first, tt.last); got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
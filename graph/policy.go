@@ -0,0 +1,315 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/BetterGR/api-gateway/graph/model"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// This file holds the ownership-aware authorization policies. The @hasRole directive
+// decides who may call a field at all; the policies here decide whose records a caller
+// may see. The caller is identified by the subject of the verified token, which is the
+// user's ID in the students and staff microservices.
+//
+//   - Admins may access every record.
+//   - Students may access only their own records, and see the staff of their courses.
+//   - Staff may access the records of the courses they are assigned to, and the profiles
+//     of students enrolled in at least one of those courses. Grades are checked course by
+//     course, so sharing one course with a student does not reveal their other grades.
+//
+// The fetchers apply these policies, so that a record is checked the same way whether it is
+// requested from a root field or reached through nested fields.
+
+// authorizeStudentRecord checks that the caller may read the records of a student
+func (r *Resolver) authorizeStudentRecord(ctx context.Context, studentID string) error {
+	claims := GetClaims(ctx)
+	if claims == nil {
+		return unauthenticatedError(ctx)
+	}
+
+	if claims.HasRole(model.RoleAdmin) || isSelf(claims, studentID) {
+		return nil
+	}
+
+	if claims.HasRole(model.RoleStaff) {
+		shares, err := r.staffSharesCourseWithStudent(ctx, claims.Subject, studentID)
+		if err != nil {
+			return err
+		}
+		if shares {
+			return nil
+		}
+	}
+
+	return forbiddenError(ctx, "you may only access your own records or those of students in your courses")
+}
+
+// authorizeStudentCourseRecord checks that the caller may read a student's records in a course
+func (r *Resolver) authorizeStudentCourseRecord(ctx context.Context, studentID, courseID string) error {
+	claims := GetClaims(ctx)
+	if claims == nil {
+		return unauthenticatedError(ctx)
+	}
+
+	if claims.HasRole(model.RoleAdmin) || isSelf(claims, studentID) {
+		return nil
+	}
+
+	if claims.HasRole(model.RoleStaff) {
+		assigned, err := r.isAssignedToCourse(ctx, claims.Subject, courseID)
+		if err != nil {
+			return err
+		}
+		if assigned {
+			return nil
+		}
+	}
+
+	return forbiddenError(ctx, "you may only access your own records or those of your courses")
+}
+
+// authorizeStaffRecord checks that the caller may read the profile of a staff member: their
+// own, or that of a staff member of one of their courses
+func (r *Resolver) authorizeStaffRecord(ctx context.Context, staffID string) error {
+	claims := GetClaims(ctx)
+	if claims == nil {
+		return unauthenticatedError(ctx)
+	}
+
+	if claims.HasRole(model.RoleAdmin) || isSelf(claims, staffID) {
+		return nil
+	}
+
+	staffCourseIDs, err := r.loaders(ctx).StaffCourseIDs.Load(ctx, staffID)
+	if err != nil {
+		return err
+	}
+
+	for _, courseID := range staffCourseIDs {
+		if _, err := r.courseRecordsScope(ctx, courseID); err == nil {
+			return nil
+		} else if !isForbidden(err) {
+			return err
+		}
+	}
+
+	return forbiddenError(ctx, "you may only access the staff of your courses")
+}
+
+// filterVisibleGrades keeps the grades the caller may read: all of them for admins, their own
+// for students, and those of the courses they are assigned to for staff
+func (r *Resolver) filterVisibleGrades(ctx context.Context, grades []*model.Grade) ([]*model.Grade, error) {
+	claims := GetClaims(ctx)
+	if claims == nil {
+		return nil, unauthenticatedError(ctx)
+	}
+
+	if claims.HasRole(model.RoleAdmin) {
+		return grades, nil
+	}
+
+	visible := make([]*model.Grade, 0, len(grades))
+	for _, g := range grades {
+		ok, err := r.gradeVisible(ctx, claims, g)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			visible = append(visible, g)
+		}
+	}
+
+	return visible, nil
+}
+
+// gradeVisible reports whether the caller may read a grade
+func (r *Resolver) gradeVisible(ctx context.Context, claims *Claims, g *model.Grade) (bool, error) {
+	if claims.HasRole(model.RoleAdmin) || isSelf(claims, g.StudentID) {
+		return true, nil
+	}
+
+	if claims.HasRole(model.RoleStaff) {
+		return r.isAssignedToCourse(ctx, claims.Subject, g.CourseID)
+	}
+
+	return false, nil
+}
+
+// filterVisibleSubmissions keeps the submissions the caller may read, checked against the
+// course of their homework like grades
+func (r *Resolver) filterVisibleSubmissions(ctx context.Context, submissions []*model.Submission) ([]*model.Submission, error) {
+	claims := GetClaims(ctx)
+	if claims == nil {
+		return nil, unauthenticatedError(ctx)
+	}
+
+	if claims.HasRole(model.RoleAdmin) {
+		return submissions, nil
+	}
+
+	visible := make([]*model.Submission, 0, len(submissions))
+	for _, s := range submissions {
+		if isSelf(claims, s.StudentID) {
+			visible = append(visible, s)
+			continue
+		}
+		if !claims.HasRole(model.RoleStaff) {
+			continue
+		}
+
+		homework, err := r.loaders(ctx).Homework.Load(ctx, s.HomeworkID)
+		if err != nil {
+			return nil, err
+		}
+		assigned, err := r.isAssignedToCourse(ctx, claims.Subject, homework.CourseID)
+		if err != nil {
+			return nil, err
+		}
+		if assigned {
+			visible = append(visible, s)
+		}
+	}
+
+	return visible, nil
+}
+
+// courseRecordsScope decides which of a course's records the caller may read. Admins and
+// staff assigned to the course may read all of them, in which case the returned student ID
+// is empty. Students enrolled in the course may read only their own records, so their ID is
+// returned for the caller to filter on.
+func (r *Resolver) courseRecordsScope(ctx context.Context, courseID string) (string, error) {
+	claims := GetClaims(ctx)
+	if claims == nil {
+		return "", unauthenticatedError(ctx)
+	}
+
+	if claims.HasRole(model.RoleAdmin) {
+		return "", nil
+	}
+
+	if claims.HasRole(model.RoleStaff) {
+		assigned, err := r.isAssignedToCourse(ctx, claims.Subject, courseID)
+		if err != nil {
+			return "", err
+		}
+		if assigned {
+			return "", nil
+		}
+	}
+
+	if claims.HasRole(model.RoleStudent) {
+		enrolled, err := r.isEnrolledInCourse(ctx, claims.Subject, courseID)
+		if err != nil {
+			return "", err
+		}
+		if enrolled {
+			return claims.Subject, nil
+		}
+	}
+
+	return "", forbiddenError(ctx, "you may only access the records of your courses")
+}
+
+// authorizeSubmission checks that the caller may submit homework in a course on behalf of
+// a student. Only the student themselves may submit, and only in courses they are enrolled in.
+func (r *Resolver) authorizeSubmission(ctx context.Context, studentID, courseID string) error {
+	claims := GetClaims(ctx)
+	if claims == nil {
+		return unauthenticatedError(ctx)
+	}
+
+	if !isSelf(claims, studentID) {
+		return forbiddenError(ctx, "you may only submit your own homework")
+	}
+
+	enrolled, err := r.isEnrolledInCourse(ctx, studentID, courseID)
+	if err != nil {
+		return err
+	}
+	if !enrolled {
+		return forbiddenError(ctx, "you may only submit homework in your courses")
+	}
+
+	return nil
+}
+
+// isSelf reports whether the caller is the given user
+func isSelf(claims *Claims, userID string) bool {
+	return claims.Subject != "" && claims.Subject == userID
+}
+
+// isAssignedToCourse reports whether a staff member is assigned to a course
+func (r *Resolver) isAssignedToCourse(ctx context.Context, staffID, courseID string) (bool, error) {
+	courseIDs, err := r.loaders(ctx).StaffCourseIDs.Load(ctx, staffID)
+	if err != nil {
+		return false, err
+	}
+
+	return slices.Contains(courseIDs, courseID), nil
+}
+
+// isEnrolledInCourse reports whether a student is enrolled in a course
+func (r *Resolver) isEnrolledInCourse(ctx context.Context, studentID, courseID string) (bool, error) {
+	courseIDs, err := r.loaders(ctx).StudentCourseIDs.Load(ctx, studentID)
+	if err != nil {
+		return false, err
+	}
+
+	return slices.Contains(courseIDs, courseID), nil
+}
+
+// staffSharesCourseWithStudent reports whether a student is enrolled in any course the staff member is assigned to
+func (r *Resolver) staffSharesCourseWithStudent(ctx context.Context, staffID, studentID string) (bool, error) {
+	loaders := r.loaders(ctx)
+
+	staffCourseIDs, err := loaders.StaffCourseIDs.Load(ctx, staffID)
+	if err != nil {
+		return false, err
+	}
+
+	studentCourseIDs, err := loaders.StudentCourseIDs.Load(ctx, studentID)
+	if err != nil {
+		return false, err
+	}
+
+	for _, courseID := range studentCourseIDs {
+		if slices.Contains(staffCourseIDs, courseID) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// filterGradesByStudent keeps only the grades of the given student
+func filterGradesByStudent(grades []*model.Grade, studentID string) []*model.Grade {
+	filtered := make([]*model.Grade, 0, len(grades))
+	for _, g := range grades {
+		if g.StudentID == studentID {
+			filtered = append(filtered, g)
+		}
+	}
+
+	return filtered
+}
+
+// isForbidden reports whether err was returned by a policy denying access
+func isForbidden(err error) bool {
+	var gqlErr *gqlerror.Error
+	return errors.As(err, &gqlErr) && gqlErr.Extensions["code"] == ErrCodeForbidden
+}
+
+// forbiddenError is returned when the caller may not access the requested records
+func forbiddenError(ctx context.Context, message string) error {
+	return &gqlerror.Error{
+		Message: message,
+		Path:    graphql.GetPath(ctx),
+		Extensions: map[string]any{
+			"code": ErrCodeForbidden,
+		},
+	}
+}
@@ -0,0 +1,227 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/BetterGR/api-gateway/graph/model"
+)
+
+// studentIDsOf decodes the local IDs of the students listed under field in data
+func studentIDsOf(t *testing.T, data json.RawMessage, field string) []string {
+	t.Helper()
+
+	var decoded map[string][]*struct {
+		ID    string `json:"id"`
+		Email string `json:"email"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []string
+	for _, s := range decoded[field] {
+		if s != nil {
+			_, id, _ := parseGlobalID(s.ID)
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func TestStudentOnlySeesOwnProfile(t *testing.T) {
+	r := newFakeSchool().resolver()
+
+	res := executeAs(t, r, "s1", model.RoleStudent, `{ student(id: "s3") { email } }`)
+	if codes := res.errorCodes(); !slices.Equal(codes, []string{ErrCodeForbidden}) {
+		t.Errorf("student(s3) as s1: got error codes %v, want FORBIDDEN", codes)
+	}
+
+	res = executeAs(t, r, "s1", model.RoleStudent, `{ student(id: "s1") { email } }`)
+	if len(res.Errors) > 0 {
+		t.Errorf("student(s1) as s1: unexpected errors %v", res.Errors)
+	}
+}
+
+func TestStudentCannotListClassmates(t *testing.T) {
+	r := newFakeSchool().resolver()
+
+	res := executeAs(t, r, "s1", model.RoleStudent, `{ courseStudents(courseId: "c2") { id email phoneNumber } }`)
+	if len(res.Errors) > 0 {
+		t.Fatalf("unexpected errors %v", res.Errors)
+	}
+	if ids := studentIDsOf(t, res.Data, "courseStudents"); !slices.Equal(ids, []string{"s1"}) {
+		t.Errorf("courseStudents(c2) as s1: got %v, want only s1", ids)
+	}
+
+	res = executeAs(t, r, "s1", model.RoleStudent, `{ course(id: "c2") { students { id email courses { id } } } }`)
+	if len(res.Errors) > 0 {
+		t.Fatalf("unexpected errors %v", res.Errors)
+	}
+	var course struct {
+		Course json.RawMessage `json:"course"`
+	}
+	if err := json.Unmarshal(res.Data, &course); err != nil {
+		t.Fatal(err)
+	}
+	if ids := studentIDsOf(t, course.Course, "students"); !slices.Equal(ids, []string{"s1"}) {
+		t.Errorf("course(c2).students as s1: got %v, want only s1", ids)
+	}
+
+	res = executeAs(t, r, "s1", model.RoleStudent, `{ courseStudentsConnection(courseId: "c2") { totalCount } }`)
+	if len(res.Errors) > 0 {
+		t.Fatalf("unexpected errors %v", res.Errors)
+	}
+	var connection struct {
+		CourseStudentsConnection struct {
+			TotalCount int `json:"totalCount"`
+		} `json:"courseStudentsConnection"`
+	}
+	if err := json.Unmarshal(res.Data, &connection); err != nil {
+		t.Fatal(err)
+	}
+	if n := connection.CourseStudentsConnection.TotalCount; n != 1 {
+		t.Errorf("courseStudentsConnection(c2) as s1: got %d students, want 1", n)
+	}
+}
+
+func TestStudentCannotReadOtherStudentsCourses(t *testing.T) {
+	r := newFakeSchool().resolver()
+
+	res := executeAs(t, r, "s1", model.RoleStudent, `{ studentCourses(studentId: "s3") { id } }`)
+	if codes := res.errorCodes(); !slices.Equal(codes, []string{ErrCodeForbidden}) {
+		t.Errorf("studentCourses(s3) as s1: got error codes %v, want FORBIDDEN", codes)
+	}
+}
+
+func TestStaffVisibility(t *testing.T) {
+	r := newFakeSchool().resolver()
+
+	tests := []struct {
+		name      string
+		subject   string
+		role      model.Role
+		query     string
+		forbidden bool
+	}{
+		{"staff reads student of their course", "t1", model.RoleStaff, `{ student(id: "s2") { email } }`, false},
+		{"staff reads student outside their courses", "t1", model.RoleStaff, `{ student(id: "s3") { email } }`, true},
+		{"staff lists students of their course", "t1", model.RoleStaff, `{ courseStudents(courseId: "c1") { email } }`, false},
+		{"staff lists students of another course", "t1", model.RoleStaff, `{ courseStudents(courseId: "c2") { email } }`, true},
+		{"staff lists staff of another course", "t1", model.RoleStaff, `{ courseStaff(courseId: "c2") { email } }`, true},
+		{"student reads staff of their course", "s3", model.RoleStudent, `{ staff(id: "t2") { email } }`, false},
+		{"student reads staff outside their courses", "s3", model.RoleStudent, `{ staff(id: "t1") { email } }`, true},
+		{"admin lists students of any course", "a1", model.RoleAdmin, `{ courseStudents(courseId: "c2") { email } }`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := executeAs(t, r, tt.subject, tt.role, tt.query)
+			codes := res.errorCodes()
+			if tt.forbidden && !slices.Contains(codes, ErrCodeForbidden) {
+				t.Errorf("got error codes %v, want FORBIDDEN", codes)
+			}
+			if !tt.forbidden && len(codes) > 0 {
+				t.Errorf("unexpected errors %v", res.Errors)
+			}
+		})
+	}
+}
+
+func TestStaffOnlySeesGradesOfTheirCourses(t *testing.T) {
+	r := newFakeSchool().resolver()
+
+	for _, query := range []string{
+		`{ grades: studentSemesterGrades(studentId: "s2", semester: "2025-spring") { courseId } }`,
+		`{ grades(studentId: "s2") { courseId } }`,
+	} {
+		res := executeAs(t, r, "t1", model.RoleStaff, query)
+		if len(res.Errors) > 0 {
+			t.Fatalf("%s: unexpected errors %v", query, res.Errors)
+		}
+
+		var data struct {
+			Grades []struct {
+				CourseID string `json:"courseId"`
+			} `json:"grades"`
+		}
+		if err := json.Unmarshal(res.Data, &data); err != nil {
+			t.Fatal(err)
+		}
+		if len(data.Grades) != 1 || data.Grades[0].CourseID != globalID(nodeTypeCourse, "c1") {
+			t.Errorf("%s as t1: got %+v, want only the c1 grade", query, data.Grades)
+		}
+	}
+
+	// The student sees all of their grades
+	res := executeAs(t, r, "s2", model.RoleStudent, `{ studentSemesterGrades(studentId: "s2", semester: "2025-spring") { id } }`)
+	var data struct {
+		StudentSemesterGrades []struct{ ID string } `json:"studentSemesterGrades"`
+	}
+	if err := json.Unmarshal(res.Data, &data); err != nil {
+		t.Fatal(err)
+	}
+	if len(data.StudentSemesterGrades) != 2 {
+		t.Errorf("studentSemesterGrades as s2: got %d grades, want 2", len(data.StudentSemesterGrades))
+	}
+}
+
+func TestGradePublishedOnlyDeliversGradesOfStaffCourses(t *testing.T) {
+	r := newFakeSchool().resolver()
+	defer r.Close()
+
+	ctx, cancel := context.WithTimeout(contextWithClaims(context.Background(), "t1", model.RoleStaff.String()), 5*time.Second)
+	defer cancel()
+
+	events, err := (&subscriptionResolver{r}).GradePublished(ctx, "s2", "2025-spring")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	topic := gradesTopic("s2", "2025-spring")
+	r.publishEvent(ctx, topic, &model.Grade{ID: "g3", StudentID: "s2", CourseID: "c2", Semester: "2025-spring"})
+	r.publishEvent(ctx, topic, &model.Grade{ID: "g2", StudentID: "s2", CourseID: "c1", Semester: "2025-spring"})
+
+	select {
+	case grade := <-events:
+		if grade.CourseID != "c1" {
+			t.Errorf("got a grade of %s, want only grades of c1", grade.CourseID)
+		}
+	case <-ctx.Done():
+		t.Fatal("no grade delivered")
+	}
+}
+
+func TestAnnouncementsOnlyVisibleToCourseMembers(t *testing.T) {
+	r := newFakeSchool().resolver()
+
+	a1 := globalID(nodeTypeAnnouncement, announcementKey{CourseID: "c1", AnnouncementID: "a1"}.String())
+	queries := []string{
+		`{ announcementsByCourse(courseId: "c1") { title } }`,
+		`{ course(id: "c1") { announcements { title } } }`,
+		`{ announcement(id: "` + a1 + `") { title } }`,
+		`{ node(id: "` + a1 + `") { ... on Announcement { title } } }`,
+	}
+
+	for _, query := range queries {
+		// s3 takes c2 only and t2 teaches c2 only
+		for _, caller := range []struct {
+			subject string
+			role    model.Role
+		}{{"s3", model.RoleStudent}, {"t2", model.RoleStaff}} {
+			res := executeAs(t, r, caller.subject, caller.role, query)
+			if codes := res.errorCodes(); !slices.Contains(codes, ErrCodeForbidden) {
+				t.Errorf("%s as %s: got error codes %v, want FORBIDDEN", query, caller.subject, codes)
+			}
+		}
+
+		res := executeAs(t, r, "s1", model.RoleStudent, query)
+		if len(res.Errors) > 0 {
+			t.Errorf("%s as s1: unexpected errors %v", query, res.Errors)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+// Package pubsub carries the events behind the GraphQL subscriptions. Mutations publish an
+// event on a topic naming what changed, such as the grades of a student in a semester, and
+// every subscription open on that topic receives its payload. Delivery is best effort:
+// subscribers only see what is published while they are subscribed, and a subscriber that
+// falls behind misses messages rather than slowing down the mutation that published them.
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// DefaultBufferSize is how many messages a subscriber may lag behind before messages are dropped for it
+const DefaultBufferSize = 16
+
+// ErrClosed is returned when publishing to or subscribing on a closed bus
+var ErrClosed = errors.New("pubsub: bus is closed")
+
+// Bus delivers messages published on a topic to the subscribers of that topic
+type Bus interface {
+	// Publish delivers payload to the current subscribers of topic
+	Publish(ctx context.Context, topic string, payload []byte) error
+
+	// Subscribe returns a channel receiving the payloads published on topic. The channel
+	// is closed once ctx is done or the bus is closed.
+	Subscribe(ctx context.Context, topic string) (<-chan []byte, error)
+
+	// Close stops the bus and closes all subscriptions
+	Close() error
+}
+
+// MemoryBus is a Bus handing messages over between the goroutines of the process, so
+// subscriptions only receive the events of the mutations served by the same gateway instance.
+type MemoryBus struct {
+	bufferSize int
+
+	mu          sync.Mutex
+	closed      bool
+	subscribers map[string]map[chan []byte]struct{}
+}
+
+// NewMemoryBus creates an in-process bus
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{
+		bufferSize:  DefaultBufferSize,
+		subscribers: make(map[string]map[chan []byte]struct{}),
+	}
+}
+
+// Publish delivers payload to the current subscribers of topic. Subscribers that are
+// too slow to keep up miss the message rather than blocking the publisher.
+func (b *MemoryBus) Publish(_ context.Context, topic string, payload []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return ErrClosed
+	}
+
+	for ch := range b.subscribers[topic] {
+		select {
+		case ch <- payload:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// Subscribe returns a channel receiving the payloads published on topic until ctx is done
+func (b *MemoryBus) Subscribe(ctx context.Context, topic string) (<-chan []byte, error) {
+	ch := make(chan []byte, b.bufferSize)
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil, ErrClosed
+	}
+	if b.subscribers[topic] == nil {
+		b.subscribers[topic] = make(map[chan []byte]struct{})
+	}
+	b.subscribers[topic][ch] = struct{}{}
+	b.mu.Unlock()
+
+	// Unsubscribe once the subscriber goes away
+	go func() {
+		<-ctx.Done()
+		b.unsubscribe(topic, ch)
+	}()
+
+	return ch, nil
+}
+
+// Close closes all subscriptions. Further calls to Publish and Subscribe fail with ErrClosed.
+func (b *MemoryBus) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+
+	for _, subscribers := range b.subscribers {
+		for ch := range subscribers {
+			close(ch)
+		}
+	}
+	b.subscribers = nil
+
+	return nil
+}
+
+// unsubscribe removes ch from the subscribers of topic and closes it
+func (b *MemoryBus) unsubscribe(topic string, ch chan []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// The channel was already closed by Close
+	if b.closed {
+		return
+	}
+
+	delete(b.subscribers[topic], ch)
+	if len(b.subscribers[topic]) == 0 {
+		delete(b.subscribers, topic)
+	}
+	close(ch)
+}
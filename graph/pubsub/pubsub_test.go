@@ -0,0 +1,157 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// receive returns the next message of ch, failing the test when none arrives in time
+func receive(t *testing.T, ch <-chan []byte) ([]byte, bool) {
+	t.Helper()
+
+	select {
+	case payload, ok := <-ch:
+		return payload, ok
+	case <-time.After(time.Second):
+		t.Fatal("no message received")
+		return nil, false
+	}
+}
+
+// assertEmpty fails the test when ch holds a message
+func assertEmpty(t *testing.T, ch <-chan []byte) {
+	t.Helper()
+
+	select {
+	case payload := <-ch:
+		t.Errorf("got unexpected message %q", payload)
+	default:
+	}
+}
+
+func TestMemoryBusDeliversToTheSubscribersOfTheTopic(t *testing.T) {
+	bus := NewMemoryBus()
+	defer bus.Close()
+
+	ctx := context.Background()
+	first, err := bus.Subscribe(ctx, "grades:s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := bus.Subscribe(ctx, "grades:s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := bus.Subscribe(ctx, "grades:s2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := bus.Publish(ctx, "grades:s1", []byte("80")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ch := range []<-chan []byte{first, second} {
+		if payload, _ := receive(t, ch); string(payload) != "80" {
+			t.Errorf("got %q, want 80", payload)
+		}
+	}
+	assertEmpty(t, other)
+}
+
+func TestMemoryBusUnsubscribesOnCancel(t *testing.T) {
+	bus := NewMemoryBus()
+	defer bus.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, err := bus.Subscribe(ctx, "grades:s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cancel()
+	if _, ok := receive(t, ch); ok {
+		t.Fatal("channel still open after the subscriber went away")
+	}
+
+	bus.mu.Lock()
+	subscribers := len(bus.subscribers)
+	bus.mu.Unlock()
+	if subscribers != 0 {
+		t.Errorf("got %d topics with subscribers, want none", subscribers)
+	}
+
+	// Publishing to a topic without subscribers is not an error
+	if err := bus.Publish(context.Background(), "grades:s1", []byte("80")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMemoryBusDropsMessagesForSlowSubscribers(t *testing.T) {
+	bus := NewMemoryBus()
+	defer bus.Close()
+
+	ctx := context.Background()
+	slow, err := bus.Subscribe(ctx, "grades:s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Publishing does not wait for the subscriber to catch up
+	published := make(chan struct{})
+	go func() {
+		defer close(published)
+		for i := range DefaultBufferSize + 5 {
+			if err := bus.Publish(ctx, "grades:s1", fmt.Appendf(nil, "%d", i)); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		t.Fatal("Publish blocked on a slow subscriber")
+	}
+
+	// The subscriber gets the messages that fit in its buffer, the rest are dropped
+	for i := range DefaultBufferSize {
+		if payload, _ := receive(t, slow); string(payload) != fmt.Sprint(i) {
+			t.Fatalf("got %q, want %d", payload, i)
+		}
+	}
+	assertEmpty(t, slow)
+}
+
+func TestMemoryBusClose(t *testing.T) {
+	bus := NewMemoryBus()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch, err := bus.Subscribe(ctx, "grades:s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := bus.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := receive(t, ch); ok {
+		t.Error("channel still open after Close")
+	}
+
+	// Cancelling a subscription after Close leaves the closed channel alone
+	cancel()
+
+	if err := bus.Publish(context.Background(), "grades:s1", []byte("80")); !errors.Is(err, ErrClosed) {
+		t.Errorf("Publish: got %v, want ErrClosed", err)
+	}
+	if _, err := bus.Subscribe(context.Background(), "grades:s1"); !errors.Is(err, ErrClosed) {
+		t.Errorf("Subscribe: got %v, want ErrClosed", err)
+	}
+	if err := bus.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+}
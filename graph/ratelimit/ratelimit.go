@@ -0,0 +1,117 @@
+// Package ratelimit implements token buckets keyed by client. A bucket holds up to Burst
+// tokens and regains Rate tokens per second; a request takes one or more tokens and, when
+// the bucket runs short, is told how long to wait until enough of them are back. Which
+// limit applies to which key is up to the caller.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often the in-memory store forgets the buckets that have refilled
+const sweepInterval = time.Minute
+
+// Limit describes a token bucket: it holds up to Burst tokens and regains Rate tokens per second
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Result is the outcome of taking tokens from a bucket
+type Result struct {
+	// Allowed reports whether the tokens were taken
+	Allowed bool
+	// Remaining is the number of whole tokens left in the bucket
+	Remaining int
+	// RetryAfter is how long to wait until enough tokens are available, zero when allowed
+	RetryAfter time.Duration
+}
+
+// Store keeps a token bucket per key
+type Store interface {
+	// Take removes cost tokens from the bucket of key when it holds enough of them. Buckets
+	// start full.
+	Take(ctx context.Context, key string, cost int, limit Limit) (Result, error)
+}
+
+// MemoryStore is a Store keeping the buckets in memory, so each gateway instance counts the
+// requests it serves on its own. Buckets that have refilled are forgotten every sweepInterval,
+// since a missing bucket starts full anyway.
+type MemoryStore struct {
+	now func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// bucket is the state of a token bucket at the time it was last used
+type bucket struct {
+	tokens float64
+	at     time.Time
+	full   time.Time
+}
+
+// NewMemoryStore creates an in-process store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Take refills the bucket of key for the time elapsed since it was last used, then removes
+// cost tokens from it if it holds enough of them
+func (s *MemoryStore) Take(_ context.Context, key string, cost int, limit Limit) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), at: now}
+		s.buckets[key] = b
+	}
+
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+now.Sub(b.at).Seconds()*limit.Rate)
+	b.at = now
+
+	res := Result{Allowed: b.tokens >= float64(cost)}
+	if res.Allowed {
+		b.tokens -= float64(cost)
+	} else {
+		res.RetryAfter = refillTime(float64(cost)-b.tokens, limit.Rate)
+	}
+	res.Remaining = int(b.tokens)
+	b.full = now.Add(refillTime(float64(limit.Burst)-b.tokens, limit.Rate))
+
+	return res, nil
+}
+
+// sweep forgets the buckets that have refilled since they were last used, as they are
+// equivalent to new ones
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+
+	for key, b := range s.buckets {
+		if !now.Before(b.full) {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// refillTime returns how long a bucket takes to regain tokens
+func refillTime(tokens, rate float64) time.Duration {
+	if rate <= 0 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(math.Ceil(tokens / rate * float64(time.Second)))
+}
@@ -0,0 +1,82 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreRefills(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+
+	limit := Limit{Rate: 2, Burst: 4}
+	take := func(cost int) Result {
+		t.Helper()
+		res, err := store.Take(context.Background(), "client", cost, limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	// The bucket starts full
+	if res := take(4); !res.Allowed || res.Remaining != 0 {
+		t.Fatalf("got %+v, want the 4 tokens of a new bucket", res)
+	}
+	if res := take(1); res.Allowed || res.RetryAfter != 500*time.Millisecond {
+		t.Fatalf("empty bucket: got %+v, want a retry in 500ms", res)
+	}
+
+	// 2 tokens are back after a second
+	now = now.Add(time.Second)
+	if res := take(3); res.Allowed || res.RetryAfter != 500*time.Millisecond {
+		t.Fatalf("after 1s: got %+v, want a retry in 500ms for the third token", res)
+	}
+	if res := take(2); !res.Allowed || res.Remaining != 0 {
+		t.Fatalf("after 1s: got %+v, want 2 tokens", res)
+	}
+
+	// The bucket never holds more than Burst tokens
+	now = now.Add(time.Hour)
+	if res := take(1); !res.Allowed || res.Remaining != 3 {
+		t.Fatalf("after 1h: got %+v, want a full bucket", res)
+	}
+}
+
+func TestMemoryStoreKeepsBucketsApart(t *testing.T) {
+	store := NewMemoryStore()
+	limit := Limit{Rate: 1, Burst: 1}
+
+	for _, key := range []string{"a", "b"} {
+		res, err := store.Take(context.Background(), key, 1, limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !res.Allowed {
+			t.Errorf("%s: got %+v, want its own full bucket", key, res)
+		}
+	}
+}
+
+func TestMemoryStoreForgetsRefilledBuckets(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+
+	limit := Limit{Rate: 1, Burst: 1}
+	for _, key := range []string{"a", "b"} {
+		if _, err := store.Take(context.Background(), key, 1, limit); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	now = now.Add(sweepInterval)
+	if _, err := store.Take(context.Background(), "c", 1, limit); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.buckets) != 1 {
+		t.Errorf("got %d buckets, want only the one of c", len(store.buckets))
+	}
+}
@@ -0,0 +1,298 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/BetterGR/api-gateway/graph/ratelimit"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// rateLimitKey is the key used to store the rate limiting state of a request in the context
+const rateLimitKey = contextKey("rate_limit")
+
+// RateLimits configures the budgets of the clients of the query endpoint. Queries and
+// subscriptions share one budget, mutations have their own. A budget with a zero rate is
+// not enforced.
+type RateLimits struct {
+	Queries   ratelimit.Limit
+	Mutations ratelimit.Limit
+	// TrustedProxies are the proxies whose X-Forwarded-For header is believed
+	TrustedProxies []netip.Prefix
+}
+
+// LoadRateLimits builds the rate limits from the environment. RATE_LIMIT_QUERY_RATE and
+// RATE_LIMIT_MUTATION_RATE are in requests per second, RATE_LIMIT_QUERY_BURST and
+// RATE_LIMIT_MUTATION_BURST bound how many requests may be sent at once, and TRUSTED_PROXIES
+// lists the addresses or CIDR ranges of the proxies in front of the gateway.
+func LoadRateLimits() (RateLimits, error) {
+	queries, err := loadRateLimit("RATE_LIMIT_QUERY_", "10", "20")
+	if err != nil {
+		return RateLimits{}, err
+	}
+
+	mutations, err := loadRateLimit("RATE_LIMIT_MUTATION_", "1", "10")
+	if err != nil {
+		return RateLimits{}, err
+	}
+
+	var proxies []netip.Prefix
+	for _, raw := range strings.Split(getEnvOrDefault("TRUSTED_PROXIES", ""), ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		prefix, err := parsePrefix(raw)
+		if err != nil {
+			return RateLimits{}, fmt.Errorf("TRUSTED_PROXIES must list IP addresses or CIDR ranges, got %q", raw)
+		}
+		proxies = append(proxies, prefix)
+	}
+
+	return RateLimits{Queries: queries, Mutations: mutations, TrustedProxies: proxies}, nil
+}
+
+// loadRateLimit reads the rate and burst of a budget
+func loadRateLimit(prefix, defaultRate, defaultBurst string) (ratelimit.Limit, error) {
+	rate, err := strconv.ParseFloat(getEnvOrDefault(prefix+"RATE", defaultRate), 64)
+	if err != nil || rate < 0 || math.IsInf(rate, 0) {
+		return ratelimit.Limit{}, fmt.Errorf("%sRATE must be a non-negative number of requests per second", prefix)
+	}
+
+	burst, err := strconv.Atoi(getEnvOrDefault(prefix+"BURST", defaultBurst))
+	if err != nil || burst < 1 {
+		return ratelimit.Limit{}, fmt.Errorf("%sBURST must be a positive number", prefix)
+	}
+
+	return ratelimit.Limit{Rate: rate, Burst: burst}, nil
+}
+
+// parsePrefix parses a CIDR range or a single address
+func parsePrefix(raw string) (netip.Prefix, error) {
+	if strings.Contains(raw, "/") {
+		return netip.ParsePrefix(raw)
+	}
+	addr, err := netip.ParseAddr(raw)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// RateLimiter limits how often each client may run operations. Clients are identified by
+// the subject of their verified token, or by their IP address when anonymous.
+//
+// Middleware records the address of the client, and the gqlgen extension takes tokens from
+// the budget matching the type of each operation. Operations exceeding the budget are
+// rejected with RATE_LIMITED, and Middleware turns the response into a 429 with a
+// Retry-After header.
+type RateLimiter struct {
+	store  ratelimit.Store
+	limits RateLimits
+}
+
+// rateLimitState carries the client address to the extension and the rejection back to
+// the middleware
+type rateLimitState struct {
+	clientIP   string
+	retryAfter time.Duration
+}
+
+var (
+	_ graphql.HandlerExtension        = (*RateLimiter)(nil)
+	_ graphql.OperationContextMutator = (*RateLimiter)(nil)
+)
+
+// NewRateLimiter creates a rate limiter keeping its token buckets in store
+func NewRateLimiter(store ratelimit.Store, limits RateLimits) *RateLimiter {
+	return &RateLimiter{store: store, limits: limits}
+}
+
+// ExtensionName identifies the extension to gqlgen
+func (l *RateLimiter) ExtensionName() string {
+	return "RateLimiter"
+}
+
+// Validate is called by gqlgen when the extension is registered
+func (l *RateLimiter) Validate(graphql.ExecutableSchema) error {
+	return nil
+}
+
+// Middleware records the address of the client and answers 429 when an operation was
+// rejected for exceeding its budget
+func (l *RateLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		state := &rateLimitState{clientIP: l.clientIP(r)}
+		ctx := context.WithValue(r.Context(), rateLimitKey, state)
+
+		next.ServeHTTP(&rateLimitWriter{writerWrapper: writerWrapper{w}, state: state}, r.WithContext(ctx))
+	})
+}
+
+// MutateOperationContext takes tokens from the budget of the client for the operation about
+// to be executed. Mutations cost a token per mutation field, so that batching them in a
+// single operation does not get around the budget.
+func (l *RateLimiter) MutateOperationContext(ctx context.Context, oc *graphql.OperationContext) *gqlerror.Error {
+	if oc.Operation == nil {
+		return nil
+	}
+
+	limit, budget, cost := l.limits.Queries, "query", 1
+	if oc.Operation.Operation == ast.Mutation {
+		limit, budget, cost = l.limits.Mutations, "mutation", rootFieldCount(oc.Operation.SelectionSet)
+	}
+	if limit.Rate <= 0 || cost == 0 {
+		return nil
+	}
+
+	state, _ := ctx.Value(rateLimitKey).(*rateLimitState)
+	client := rateLimitClient(ctx, state)
+	if client == "" {
+		return nil
+	}
+
+	if cost > limit.Burst {
+		return rateLimitError(fmt.Sprintf("operation has %d mutations, at most %d may be sent at once", cost, limit.Burst), 0)
+	}
+
+	res, err := l.store.Take(ctx, budget+":"+client, cost, limit)
+	if err != nil {
+		// Keep serving when the store is unreachable rather than rejecting every client
+		LoggerFromContext(ctx).Warn("rate limit store failed", "error", err)
+		return nil
+	}
+	if res.Allowed {
+		return nil
+	}
+
+	if state != nil {
+		state.retryAfter = res.RetryAfter
+	}
+
+	return rateLimitError(fmt.Sprintf("too many %s requests, retry in %ss", budget, retryAfterSeconds(res.RetryAfter)), res.RetryAfter)
+}
+
+// rateLimitClient identifies the client of a request by the subject of its verified token,
+// or by its address when anonymous
+func rateLimitClient(ctx context.Context, state *rateLimitState) string {
+	if claims := GetClaims(ctx); claims != nil && claims.Subject != "" {
+		return "sub:" + claims.Subject
+	}
+	if state != nil && state.clientIP != "" {
+		return "ip:" + state.clientIP
+	}
+	return ""
+}
+
+// rateLimitError builds the error returned for a rejected operation
+func rateLimitError(message string, retryAfter time.Duration) *gqlerror.Error {
+	err := gqlerror.Errorf("%s", message)
+	err.Extensions = map[string]any{"code": ErrCodeRateLimited}
+	if retryAfter > 0 {
+		err.Extensions["retryAfter"] = math.Ceil(retryAfter.Seconds())
+	}
+	return err
+}
+
+// retryAfterSeconds formats a delay as the whole number of seconds expected by Retry-After
+func retryAfterSeconds(d time.Duration) string {
+	return strconv.FormatFloat(math.Ceil(d.Seconds()), 'f', 0, 64)
+}
+
+// rootFieldCount counts the fields selected at the root of an operation, through fragments
+func rootFieldCount(set ast.SelectionSet) int {
+	count := 0
+	for _, selection := range set {
+		switch s := selection.(type) {
+		case *ast.Field:
+			if !strings.HasPrefix(s.Name, "__") {
+				count++
+			}
+		case *ast.InlineFragment:
+			count += rootFieldCount(s.SelectionSet)
+		case *ast.FragmentSpread:
+			if s.Definition != nil {
+				count += rootFieldCount(s.Definition.SelectionSet)
+			}
+		}
+	}
+	return count
+}
+
+// clientIP returns the address of the client. The X-Forwarded-For header is only believed
+// when the request comes from a trusted proxy, in which case the client is the last address
+// in the header that is not a trusted proxy itself.
+func (l *RateLimiter) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	remote, err := netip.ParseAddr(host)
+	if err != nil || !l.trusted(remote) {
+		return host
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		if !l.trusted(hop) {
+			return hop.Unmap().String()
+		}
+		remote = hop
+	}
+
+	return remote.Unmap().String()
+}
+
+// trusted reports whether addr belongs to a trusted proxy
+func (l *RateLimiter) trusted(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range l.limits.TrustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// rateLimitWriter replaces the status of responses to rejected operations with 429
+type rateLimitWriter struct {
+	writerWrapper
+	state       *rateLimitState
+	wroteHeader bool
+}
+
+// WriteHeader sends 429 and Retry-After instead of code when the operation was rejected
+func (w *rateLimitWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	if w.state.retryAfter > 0 {
+		w.Header().Set("Retry-After", retryAfterSeconds(w.state.retryAfter))
+		code = http.StatusTooManyRequests
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write sends the headers first if they have not been yet
+func (w *rateLimitWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
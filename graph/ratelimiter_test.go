@@ -0,0 +1,154 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/BetterGR/api-gateway/graph/ratelimit"
+)
+
+// failingStore is a rate limit store that cannot be reached
+type failingStore struct{}
+
+func (failingStore) Take(context.Context, string, int, ratelimit.Limit) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("connection refused")
+}
+
+// rateLimitedServer serves anonymous queries through limiter, as the gateway does
+func rateLimitedServer(limiter *RateLimiter) http.Handler {
+	srv := newTestServer(newFakeSchool().resolver())
+	srv.Use(limiter)
+	return limiter.Middleware(srv)
+}
+
+// postQuery sends an anonymous query to h from remoteAddr, through the proxies listed in
+// forwardedFor if any
+func postQuery(t *testing.T, h http.Handler, remoteAddr, forwardedFor string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	body, err := json.Marshal(map[string]any{"query": `{ __typename }`})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/query", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = remoteAddr
+	if forwardedFor != "" {
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestClientIPTrustsOnlyTrustedProxies(t *testing.T) {
+	limiter := NewRateLimiter(ratelimit.NewMemoryStore(), RateLimits{
+		TrustedProxies: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+	})
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor string
+		want         string
+	}{
+		{name: "direct client", remoteAddr: "203.0.113.7:4000", want: "203.0.113.7"},
+		{name: "spoofed header from an untrusted peer", remoteAddr: "203.0.113.7:4000", forwardedFor: "198.51.100.1", want: "203.0.113.7"},
+		{name: "client behind a trusted proxy", remoteAddr: "10.0.0.2:4000", forwardedFor: "198.51.100.1", want: "198.51.100.1"},
+		{name: "client behind a chain of trusted proxies", remoteAddr: "10.0.0.2:4000", forwardedFor: "198.51.100.1, 10.0.0.3", want: "198.51.100.1"},
+		{name: "spoofed hop before the client", remoteAddr: "10.0.0.2:4000", forwardedFor: "192.0.2.9, 198.51.100.1", want: "198.51.100.1"},
+		{name: "invalid hop", remoteAddr: "10.0.0.2:4000", forwardedFor: "198.51.100.1, unknown", want: "10.0.0.2"},
+		{name: "trusted proxy without the header", remoteAddr: "10.0.0.2:4000", want: "10.0.0.2"},
+		{name: "IPv4-mapped client", remoteAddr: "10.0.0.2:4000", forwardedFor: "::ffff:198.51.100.1", want: "198.51.100.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/query", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			}
+
+			if got := limiter.clientIP(req); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimiterRejectsWith429(t *testing.T) {
+	h := rateLimitedServer(NewRateLimiter(ratelimit.NewMemoryStore(), RateLimits{
+		Queries: ratelimit.Limit{Rate: 0.5, Burst: 2},
+	}))
+
+	for i := range 2 {
+		if rec := postQuery(t, h, "203.0.113.7:4000", ""); rec.Code != http.StatusOK {
+			t.Fatalf("request %d: got status %d, want 200", i+1, rec.Code)
+		}
+	}
+
+	rec := postQuery(t, h, "203.0.113.7:4000", "")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("got status %d, want 429", rec.Code)
+	}
+	if retryAfter := rec.Header().Get("Retry-After"); retryAfter != "2" {
+		t.Errorf("got Retry-After %q, want 2", retryAfter)
+	}
+
+	var res graphQLResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if codes := res.errorCodes(); len(codes) != 1 || codes[0] != ErrCodeRateLimited {
+		t.Errorf("got error codes %v, want RATE_LIMITED", codes)
+	}
+
+	// Other clients have their own budget
+	if rec := postQuery(t, h, "203.0.113.8:4000", ""); rec.Code != http.StatusOK {
+		t.Errorf("another client: got status %d, want 200", rec.Code)
+	}
+}
+
+func TestRateLimiterIgnoresSpoofedForwardedFor(t *testing.T) {
+	h := rateLimitedServer(NewRateLimiter(ratelimit.NewMemoryStore(), RateLimits{
+		Queries:        ratelimit.Limit{Rate: 0.5, Burst: 1},
+		TrustedProxies: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+	}))
+
+	// An untrusted client changing its X-Forwarded-For header keeps its budget
+	if rec := postQuery(t, h, "203.0.113.7:4000", "198.51.100.1"); rec.Code != http.StatusOK {
+		t.Fatalf("got status %d, want 200", rec.Code)
+	}
+	if rec := postQuery(t, h, "203.0.113.7:4000", "198.51.100.2"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("spoofed client: got status %d, want 429", rec.Code)
+	}
+
+	// Clients behind a trusted proxy each have their own budget
+	if rec := postQuery(t, h, "10.0.0.2:4000", "198.51.100.1"); rec.Code != http.StatusOK {
+		t.Errorf("first client behind the proxy: got status %d, want 200", rec.Code)
+	}
+	if rec := postQuery(t, h, "10.0.0.2:4000", "198.51.100.2"); rec.Code != http.StatusOK {
+		t.Errorf("second client behind the proxy: got status %d, want 200", rec.Code)
+	}
+}
+
+func TestRateLimiterFailsOpen(t *testing.T) {
+	h := rateLimitedServer(NewRateLimiter(failingStore{}, RateLimits{
+		Queries: ratelimit.Limit{Rate: 0.5, Burst: 1},
+	}))
+
+	for i := range 3 {
+		if rec := postQuery(t, h, "203.0.113.7:4000", ""); rec.Code != http.StatusOK {
+			t.Fatalf("request %d: got status %d, want 200 while the store is down", i+1, rec.Code)
+		}
+	}
+}
@@ -3,8 +3,11 @@ package graph
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"os"
 
+	"github.com/BetterGR/api-gateway/graph/blobstore"
+	"github.com/BetterGR/api-gateway/graph/pubsub"
 	coursespb "github.com/BetterGR/courses-microservice/protos"
 	gradespb "github.com/BetterGR/grades-microservice/protos"
 	staffpb "github.com/BetterGR/staff-microservice/protos"
@@ -23,15 +26,38 @@ type Resolver struct {
 	StaffClient    staffpb.StaffServiceClient
 	CoursesClient  coursespb.CoursesServiceClient
 	GradesClient   gradespb.GradesServiceClient
+	HomeworkClient HomeworkClient
 
 	// Store connection objects to properly close them
 	studentsConn *grpc.ClientConn
 	staffConn    *grpc.ClientConn
 	coursesConn  *grpc.ClientConn
 	gradesConn   *grpc.ClientConn
+	homeworkConn *grpc.ClientConn
+
+	// Blobs stores the files uploaded with homework submissions
+	Blobs blobstore.Store
+
+	// UploadLimits restricts the files accepted for upload
+	UploadLimits UploadLimits
+
+	// Breakers guard the connections to the microservices
+	Breakers *Breakers
+
+	// Health probes the microservices for the readiness and status endpoints
+	Health *BackendHealth
+
+	// Metrics collects the Prometheus metrics of the gateway
+	Metrics *Metrics
+
+	// PubSub carries the events that feed the GraphQL subscriptions
+	PubSub pubsub.Bus
+
+	// Logger is used outside of requests, requests log with the logger in their context
+	Logger *slog.Logger
 }
 
-// Close properly closes all gRPC connections
+// Close properly closes all gRPC connections and the event bus
 func (r *Resolver) Close() {
 	if r.studentsConn != nil {
 		r.studentsConn.Close()
@@ -45,68 +71,150 @@ func (r *Resolver) Close() {
 	if r.gradesConn != nil {
 		r.gradesConn.Close()
 	}
+	if r.homeworkConn != nil {
+		r.homeworkConn.Close()
+	}
+	if r.Health != nil {
+		r.Health.Close()
+	}
+	if r.PubSub != nil {
+		r.PubSub.Close()
+	}
 }
 
 // NewResolver creates a new resolver with all the necessary gRPC clients
-func NewResolver() (*Resolver, error) {
+func NewResolver(logger *slog.Logger) (*Resolver, error) {
 	// Get microservice endpoints from environment variables or use defaults
 	gradesEndpoint := getEnvOrDefault("GRADES_PORT", "localhost:50051")
 	studentsEndpoint := getEnvOrDefault("STUDENTS_PORT", "localhost:50052")
-	// homeworkEndpoint := getEnvOrDefault("HOMEWORK_PORT", "localhost:50053")
+	// The homework microservice is optional, its fields fail until it is configured
+	homeworkEndpoint := getEnvOrDefault("HOMEWORK_PORT", "")
 	coursesEndpoint := getEnvOrDefault("COURSES_PORT", "localhost:50054")
 	staffEndpoint := getEnvOrDefault("STAFF_PORT", "localhost:50055")
 
+	// Setup storage for uploaded files
+	uploadLimits, err := LoadUploadLimits()
+	if err != nil {
+		return nil, err
+	}
+	blobs, err := blobstore.NewLocalStore(getEnvOrDefault("UPLOAD_DIR", "uploads"))
+	if err != nil {
+		return nil, err
+	}
+
+	// Deadlines, retries and hedging of the calls to each microservice
+	callPolicies, err := LoadCallPolicies()
+	if err != nil {
+		return nil, err
+	}
+
+	// Fail fast while a microservice is down
+	breakerSettings, err := LoadBreakerSettings()
+	if err != nil {
+		return nil, err
+	}
+	breakers := NewBreakers(backendServices, breakerSettings, logger)
+
+	// Measure the calls made to the microservices
+	metrics := NewMetrics(LoadMetricsOperations())
+
+	// Keep track of the connections for the readiness and status endpoints
+	health := NewBackendHealth(requiredServices, optionalServices)
+
 	// Setup connection to Students microservice
 	studentsConn, err := grpc.NewClient(
 		studentsEndpoint,
 		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithChainUnaryInterceptor(clientInterceptors("students", callPolicies["students"], breakers, health, metrics)...),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to students service: %w", err)
 	}
+	if err := health.register("students", studentsEndpoint, studentsConn, grpc.WithTransportCredentials(insecure.NewCredentials())); err != nil {
+		return nil, fmt.Errorf("failed to connect to students service: %w", err)
+	}
 	studentsClient := studentspb.NewStudentsServiceClient(studentsConn)
 
 	// Setup connection to Staff microservice
 	staffConn, err := grpc.NewClient(
 		staffEndpoint,
 		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithChainUnaryInterceptor(clientInterceptors("staff", callPolicies["staff"], breakers, health, metrics)...),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to staff service: %w", err)
 	}
+	if err := health.register("staff", staffEndpoint, staffConn, grpc.WithTransportCredentials(insecure.NewCredentials())); err != nil {
+		return nil, fmt.Errorf("failed to connect to staff service: %w", err)
+	}
 	staffClient := staffpb.NewStaffServiceClient(staffConn)
 
 	// Setup connection to Courses microservice
 	coursesConn, err := grpc.NewClient(
 		coursesEndpoint,
 		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithChainUnaryInterceptor(clientInterceptors("courses", callPolicies["courses"], breakers, health, metrics)...),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to courses service: %w", err)
 	}
+	if err := health.register("courses", coursesEndpoint, coursesConn, grpc.WithTransportCredentials(insecure.NewCredentials())); err != nil {
+		return nil, fmt.Errorf("failed to connect to courses service: %w", err)
+	}
 	coursesClient := coursespb.NewCoursesServiceClient(coursesConn)
 
 	// Setup connection to Grades microservice
 	gradesConn, err := grpc.NewClient(
 		gradesEndpoint,
 		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithChainUnaryInterceptor(clientInterceptors("grades", callPolicies["grades"], breakers, health, metrics)...),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to grades service: %w", err)
 	}
+	if err := health.register("grades", gradesEndpoint, gradesConn, grpc.WithTransportCredentials(insecure.NewCredentials())); err != nil {
+		return nil, fmt.Errorf("failed to connect to grades service: %w", err)
+	}
 	gradesClient := gradespb.NewGradesServiceClient(gradesConn)
 
-	// Note: Homework service is not used directly as it's probably part of the courses service
+	// Setup connection to Homework microservice when it is configured
+	var homeworkConn *grpc.ClientConn
+	var homeworkClient HomeworkClient
+	if homeworkEndpoint != "" {
+		homeworkConn, err = grpc.NewClient(
+			homeworkEndpoint,
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithChainUnaryInterceptor(clientInterceptors(homeworkService, callPolicies[homeworkService], breakers, health, metrics)...),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to homework service: %w", err)
+		}
+		if err := health.register(homeworkService, homeworkEndpoint, homeworkConn, grpc.WithTransportCredentials(insecure.NewCredentials())); err != nil {
+			return nil, fmt.Errorf("failed to connect to homework service: %w", err)
+		}
+		homeworkClient = NewHomeworkClient(homeworkConn)
+	} else {
+		logger.Warn("HOMEWORK_PORT is not set. Homework fields will fail until it is configured.")
+	}
 
 	return &Resolver{
 		StudentsClient: studentsClient,
 		StaffClient:    staffClient,
 		CoursesClient:  coursesClient,
 		GradesClient:   gradesClient,
+		HomeworkClient: homeworkClient,
+		Blobs:          blobs,
+		Breakers:       breakers,
+		Health:         health,
+		Metrics:        metrics,
+		UploadLimits:   uploadLimits,
+		PubSub:         pubsub.NewMemoryBus(),
+		Logger:         logger,
 		studentsConn:   studentsConn,
 		staffConn:      staffConn,
 		coursesConn:    coursesConn,
 		gradesConn:     gradesConn,
+		homeworkConn:   homeworkConn,
 	}, nil
 }
 
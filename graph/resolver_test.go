@@ -0,0 +1,311 @@
+package graph
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/BetterGR/api-gateway/graph/model"
+	gradespb "github.com/BetterGR/grades-microservice/protos"
+)
+
+// identified is an object of which only the id was selected
+type identified struct {
+	ID string `json:"id"`
+}
+
+// localIDs returns the sorted local IDs of objects
+func localIDs(t *testing.T, objects []identified) []string {
+	t.Helper()
+
+	ids := make([]string, len(objects))
+	for i, obj := range objects {
+		_, id, ok := parseGlobalID(obj.ID)
+		if !ok {
+			t.Fatalf("%q is not a global ID", obj.ID)
+		}
+		ids[i] = id
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func TestNestedStudentCoursesStaffPopulate(t *testing.T) {
+	r := newFakeSchool().resolver()
+
+	res := executeAs(t, r, "s1", model.RoleStudent, `{
+		student(id: "s1") {
+			courses {
+				id
+				staff {
+					id
+					courses { id }
+				}
+			}
+		}
+	}`)
+	if len(res.Errors) > 0 {
+		t.Fatalf("unexpected errors %v", res.Errors)
+	}
+
+	var data struct {
+		Student struct {
+			Courses []struct {
+				ID    string `json:"id"`
+				Staff []struct {
+					ID      string       `json:"id"`
+					Courses []identified `json:"courses"`
+				} `json:"staff"`
+			} `json:"courses"`
+		} `json:"student"`
+	}
+	if err := json.Unmarshal(res.Data, &data); err != nil {
+		t.Fatal(err)
+	}
+
+	courses := data.Student.Courses
+	if len(courses) != 2 {
+		t.Fatalf("got %d courses, want c1 and c2", len(courses))
+	}
+
+	wantStaff := map[string]string{"c1": "t1", "c2": "t2"}
+	for _, course := range courses {
+		_, courseID, _ := parseGlobalID(course.ID)
+		if len(course.Staff) != 1 {
+			t.Fatalf("course %s: got %d staff members, want 1", courseID, len(course.Staff))
+		}
+
+		staff := course.Staff[0]
+		if _, staffID, _ := parseGlobalID(staff.ID); staffID != wantStaff[courseID] {
+			t.Errorf("course %s: got staff %s, want %s", courseID, staffID, wantStaff[courseID])
+		}
+		if ids := localIDs(t, staff.Courses); !slices.Equal(ids, []string{courseID}) {
+			t.Errorf("course %s: got staff courses %v, want [%s]", courseID, ids, courseID)
+		}
+	}
+}
+
+func TestNestedStaffCoursesStudentsPopulate(t *testing.T) {
+	r := newFakeSchool().resolver()
+
+	res := executeAs(t, r, "t2", model.RoleStaff, `{
+		staff(id: "t2") {
+			courses {
+				students { id }
+			}
+		}
+	}`)
+	if len(res.Errors) > 0 {
+		t.Fatalf("unexpected errors %v", res.Errors)
+	}
+
+	var data struct {
+		Staff struct {
+			Courses []struct {
+				Students []identified `json:"students"`
+			} `json:"courses"`
+		} `json:"staff"`
+	}
+	if err := json.Unmarshal(res.Data, &data); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(data.Staff.Courses) != 1 {
+		t.Fatalf("got %d courses, want c2", len(data.Staff.Courses))
+	}
+	if ids := localIDs(t, data.Staff.Courses[0].Students); !slices.Equal(ids, []string{"s1", "s2", "s3"}) {
+		t.Errorf("got students %v, want s1, s2 and s3", ids)
+	}
+}
+
+func TestNestedLookupsAreBatched(t *testing.T) {
+	school := newFakeSchool()
+	r := school.resolver()
+
+	// Every course of s1 lists s1 again: the loaders look each record up once
+	res := executeAs(t, r, "a1", model.RoleAdmin, `{
+		student(id: "s1") {
+			courses {
+				students { id courses { id } }
+			}
+		}
+	}`)
+	if len(res.Errors) > 0 {
+		t.Fatalf("unexpected errors %v", res.Errors)
+	}
+
+	// s1, s2 and s3, c1 and c2, the students of c1 and c2 and the courses of s1, s2 and s3
+	if calls := school.calls.Load(); calls != 3+2+2+3 {
+		t.Errorf("got %d backend calls, want 10", calls)
+	}
+}
+
+// gradeOf returns the grade of the fake school with the given microservice ID, nil once removed
+func gradeOf(school *fakeSchool, gradeID string) *gradespb.SingleGrade {
+	for _, g := range school.grades {
+		if g.GradeID == gradeID {
+			return g
+		}
+	}
+	return nil
+}
+
+func TestUpdateGrade(t *testing.T) {
+	g1 := gradeKey{CourseID: "c1", Semester: "2025-spring", StudentID: "s1", GradeType: "exam", ItemID: "final", GradeID: "g1"}
+	missing := g1
+	missing.GradeID = "g9"
+
+	tests := []struct {
+		name         string
+		id           string
+		input        string
+		wantCodes    []string
+		wantValue    string
+		wantComments string
+	}{
+		{name: "grade id", id: globalID(nodeTypeGrade, g1.String()), input: `{ gradeValue: "85", comments: "regraded" }`, wantValue: "85", wantComments: "regraded"},
+		{name: "grade id keeps the comments left out", id: globalID(nodeTypeGrade, g1.String()), input: `{ gradeValue: "85" }`, wantValue: "85", wantComments: "first try"},
+		{name: "grade id keeps the value left out", id: globalID(nodeTypeGrade, g1.String()), input: `{ comments: "regraded" }`, wantValue: "80", wantComments: "regraded"},
+		{name: "microservice id with every value", id: "g1", input: `{ gradeValue: "85", comments: "regraded" }`, wantValue: "85", wantComments: "regraded"},
+		{name: "microservice id without the comments", id: "g1", input: `{ gradeValue: "85" }`, wantCodes: []string{ErrCodeBadUserInput}, wantValue: "80", wantComments: "first try"},
+		{name: "grade id of a missing grade", id: globalID(nodeTypeGrade, missing.String()), input: `{ gradeValue: "85" }`, wantCodes: []string{ErrCodeNotFound}, wantValue: "80", wantComments: "first try"},
+		{name: "course id", id: globalID(nodeTypeCourse, "c1"), input: `{ gradeValue: "85" }`, wantCodes: []string{ErrCodeBadUserInput}, wantValue: "80", wantComments: "first try"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			school := newFakeSchool()
+			gradeOf(school, "g1").Comments = "first try"
+
+			res := executeAs(t, school.resolver(), "t1", model.RoleStaff,
+				`mutation { updateGrade(id: "`+tt.id+`", input: `+tt.input+`) { id gradeValue } }`)
+			if codes := res.errorCodes(); !slices.Equal(codes, tt.wantCodes) {
+				t.Fatalf("got error codes %v, want %v", codes, tt.wantCodes)
+			}
+
+			g := gradeOf(school, "g1")
+			if g.GradeValue != tt.wantValue || g.Comments != tt.wantComments {
+				t.Errorf("got grade %q with comments %q, want %q with %q", g.GradeValue, g.Comments, tt.wantValue, tt.wantComments)
+			}
+		})
+	}
+}
+
+func TestDeleteGrade(t *testing.T) {
+	g1 := gradeKey{CourseID: "c1", Semester: "2025-spring", StudentID: "s1", GradeType: "exam", ItemID: "final", GradeID: "g1"}
+	keyArgs := `courseId: "c1", semester: "2025-spring", studentId: "s1", gradeType: "exam", itemId: "final"`
+
+	tests := []struct {
+		name        string
+		args        string
+		wantCodes   []string
+		wantRemoved bool
+	}{
+		{name: "grade id", args: `id: "` + globalID(nodeTypeGrade, g1.String()) + `"`, wantRemoved: true},
+		{name: "grade id with matching arguments", args: `id: "` + globalID(nodeTypeGrade, g1.String()) + `", ` + keyArgs, wantRemoved: true},
+		{name: "grade id with another course", args: `id: "` + globalID(nodeTypeGrade, g1.String()) + `", courseId: "c2"`, wantCodes: []string{ErrCodeBadUserInput}},
+		{name: "microservice id with every argument", args: `id: "g1", ` + keyArgs, wantRemoved: true},
+		{name: "microservice id without the item", args: `id: "g1", courseId: "c1", semester: "2025-spring", studentId: "s1", gradeType: "exam"`, wantCodes: []string{ErrCodeBadUserInput}},
+		{name: "microservice id of another student", args: `id: "g1", courseId: "c1", semester: "2025-spring", studentId: "s2", gradeType: "exam", itemId: "final"`, wantCodes: []string{ErrCodeNotFound}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			school := newFakeSchool()
+
+			res := executeAs(t, school.resolver(), "t1", model.RoleStaff, `mutation { deleteGrade(`+tt.args+`) }`)
+			if codes := res.errorCodes(); !slices.Equal(codes, tt.wantCodes) {
+				t.Fatalf("got error codes %v, want %v", codes, tt.wantCodes)
+			}
+			if removed := gradeOf(school, "g1") == nil; removed != tt.wantRemoved {
+				t.Errorf("grade removed: %v, want %v", removed, tt.wantRemoved)
+			}
+		})
+	}
+}
+
+func TestFailedLookupsReturnPartialData(t *testing.T) {
+	school := newFakeSchool()
+	school.unavailableStudents = map[string]bool{"s2": true}
+	r := school.resolver()
+
+	tests := []struct {
+		name     string
+		query    string
+		items    func(data json.RawMessage) ([]*identified, error)
+		wantPath []any
+	}{
+		{
+			name:  "list",
+			query: `{ courseStudents(courseId: "c2") { id } }`,
+			items: func(data json.RawMessage) ([]*identified, error) {
+				var decoded struct {
+					CourseStudents []*identified `json:"courseStudents"`
+				}
+				err := json.Unmarshal(data, &decoded)
+				return decoded.CourseStudents, err
+			},
+			wantPath: []any{"courseStudents", 1.0},
+		},
+		{
+			name:  "nested list",
+			query: `{ course(id: "c2") { students { id } } }`,
+			items: func(data json.RawMessage) ([]*identified, error) {
+				var decoded struct {
+					Course struct {
+						Students []*identified `json:"students"`
+					} `json:"course"`
+				}
+				err := json.Unmarshal(data, &decoded)
+				return decoded.Course.Students, err
+			},
+			wantPath: []any{"course", "students", 1.0},
+		},
+		{
+			name:  "connection",
+			query: `{ courseStudentsConnection(courseId: "c2") { edges { node { id } } } }`,
+			items: func(data json.RawMessage) ([]*identified, error) {
+				var decoded struct {
+					Connection struct {
+						Edges []struct {
+							Node *identified `json:"node"`
+						} `json:"edges"`
+					} `json:"courseStudentsConnection"`
+				}
+				err := json.Unmarshal(data, &decoded)
+				nodes := make([]*identified, len(decoded.Connection.Edges))
+				for i, edge := range decoded.Connection.Edges {
+					nodes[i] = edge.Node
+				}
+				return nodes, err
+			},
+			wantPath: []any{"courseStudentsConnection", "edges", 1.0, "node"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := executeAs(t, r, "a1", model.RoleAdmin, tt.query)
+
+			// The students that loaded are returned, s2 is null
+			items, err := tt.items(res.Data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(items) != 3 || items[0] == nil || items[1] != nil || items[2] == nil {
+				t.Fatalf("got %s, want s1, null and s3", res.Data)
+			}
+			if ids := localIDs(t, []identified{*items[0], *items[2]}); !slices.Equal(ids, []string{"s1", "s3"}) {
+				t.Errorf("got students %v, want s1 and s3", ids)
+			}
+
+			// The failure of s2 is reported on its item alone
+			if codes := res.errorCodes(); !slices.Equal(codes, []string{ErrCodeServiceUnavailable}) {
+				t.Fatalf("got error codes %v, want SERVICE_UNAVAILABLE", codes)
+			}
+			if path := res.Errors[0].Path; !slices.Equal(path, tt.wantPath) {
+				t.Errorf("got error path %v, want %v", path, tt.wantPath)
+			}
+		})
+	}
+}
@@ -2,13 +2,13 @@ package graph
 
 // This file will be automatically regenerated based on the schema, any resolver implementations
 // will be copied through when generating and any unknown code will be moved to the end.
-// Code generated by github.com/99designs/gqlgen version v0.17.72
+// Code generated by github.com/99designs/gqlgen version v0.17.74
 
 import (
 	"context"
-	"fmt"
 	"time"
 
+	"github.com/99designs/gqlgen/graphql"
 	"github.com/BetterGR/api-gateway/graph/model"
 	coursespb "github.com/BetterGR/courses-microservice/protos"
 	gradespb "github.com/BetterGR/grades-microservice/protos"
@@ -16,6 +16,71 @@ import (
 	studentspb "github.com/BetterGR/students-microservice/protos"
 )
 
+// ID is the resolver for the id field.
+func (r *announcementResolver) ID(ctx context.Context, obj *model.Announcement) (string, error) {
+	return globalID(nodeTypeAnnouncement, announcementKey{CourseID: obj.CourseID, AnnouncementID: obj.ID}.String()), nil
+}
+
+// CourseID is the resolver for the courseId field.
+func (r *announcementResolver) CourseID(ctx context.Context, obj *model.Announcement) (string, error) {
+	return globalID(nodeTypeCourse, obj.CourseID), nil
+}
+
+// ID is the resolver for the id field.
+func (r *courseResolver) ID(ctx context.Context, obj *model.Course) (string, error) {
+	return globalID(nodeTypeCourse, obj.ID), nil
+}
+
+// Staff is the resolver for the staff field.
+func (r *courseResolver) Staff(ctx context.Context, obj *model.Course) ([]*model.Staff, error) {
+	return r.fetchCourseStaff(ctx, obj.ID)
+}
+
+// Students is the resolver for the students field.
+func (r *courseResolver) Students(ctx context.Context, obj *model.Course) ([]*model.Student, error) {
+	return r.fetchCourseStudents(ctx, obj.ID)
+}
+
+// Announcements is the resolver for the announcements field.
+func (r *courseResolver) Announcements(ctx context.Context, obj *model.Course) ([]*model.Announcement, error) {
+	return r.fetchCourseAnnouncements(ctx, obj.ID)
+}
+
+// Homework is the resolver for the homework field.
+func (r *courseResolver) Homework(ctx context.Context, obj *model.Course) ([]*model.Homework, error) {
+	return r.fetchCourseHomework(ctx, obj.ID)
+}
+
+// Grades is the resolver for the grades field.
+func (r *courseResolver) Grades(ctx context.Context, obj *model.Course) ([]*model.Grade, error) {
+	return r.fetchCourseGrades(ctx, obj.ID, obj.Semester)
+}
+
+// ID is the resolver for the id field.
+func (r *gradeResolver) ID(ctx context.Context, obj *model.Grade) (string, error) {
+	return globalID(nodeTypeGrade, gradeKeyOf(obj).String()), nil
+}
+
+// StudentID is the resolver for the studentId field.
+func (r *gradeResolver) StudentID(ctx context.Context, obj *model.Grade) (string, error) {
+	return globalID(nodeTypeStudent, obj.StudentID), nil
+}
+
+// CourseID is the resolver for the courseId field.
+func (r *gradeResolver) CourseID(ctx context.Context, obj *model.Grade) (string, error) {
+	return globalID(nodeTypeCourse, obj.CourseID), nil
+}
+
+// ID is the resolver for the id field.
+func (r *homeworkResolver) ID(ctx context.Context, obj *model.Homework) (string, error) {
+	return globalID(nodeTypeHomework, obj.ID), nil
+}
+
+// CourseID is the resolver for the courseId field.
+func (r *homeworkResolver) CourseID(ctx context.Context, obj *model.Homework) (string, error) {
+	return globalID(nodeTypeCourse, obj.CourseID), nil
+}
+
 // CreateStudent is the resolver for the createStudent field.
 func (r *mutationResolver) CreateStudent(ctx context.Context, input model.NewStudent) (*model.Student, error) {
 	// Create an authenticated context with the token
@@ -51,7 +116,6 @@ func (r *mutationResolver) CreateStudent(ctx context.Context, input model.NewStu
 		PhoneNumber: res.Student.PhoneNumber,
 		CreatedAt:   now,
 		UpdatedAt:   now,
-		Courses:     []*model.Course{}, // Initialize with empty courses
 	}
 
 	return student, nil
@@ -111,7 +175,6 @@ func (r *mutationResolver) UpdateStudent(ctx context.Context, id string, input m
 		Email:       res.Student.Email,
 		PhoneNumber: res.Student.PhoneNumber,
 		UpdatedAt:   time.Now().Format(time.RFC3339),
-		Courses:     []*model.Course{}, // We'll need to fetch courses separately
 	}
 
 	return updatedStudent, nil
@@ -179,7 +242,6 @@ func (r *mutationResolver) CreateStaff(ctx context.Context, input model.NewStaff
 		Office:      &res.StaffMember.Office,
 		CreatedAt:   now,
 		UpdatedAt:   now,
-		Courses:     []*model.Course{}, // Initialize with empty courses
 	}
 
 	return staff, nil
@@ -247,7 +309,6 @@ func (r *mutationResolver) UpdateStaff(ctx context.Context, id string, input mod
 		Title:       &res.StaffMember.Title,
 		Office:      &res.StaffMember.Office,
 		UpdatedAt:   time.Now().Format(time.RFC3339),
-		Courses:     []*model.Course{}, // We'll need to fetch courses separately
 	}
 
 	return updatedStaff, nil
@@ -303,17 +364,12 @@ func (r *mutationResolver) CreateCourse(ctx context.Context, input model.NewCour
 	// Convert the response to GraphQL model
 	now := time.Now().Format(time.RFC3339)
 	course := &model.Course{
-		ID:            res.Course.CourseID,
-		Name:          res.Course.CourseName,
-		Semester:      res.Course.Semester,
-		Description:   &res.Course.Description,
-		CreatedAt:     now,
-		UpdatedAt:     now,
-		Staff:         []*model.Staff{},
-		Students:      []*model.Student{},
-		Announcements: []*model.Announcement{},
-		Homework:      []*model.Homework{},
-		Grades:        []*model.Grade{},
+		ID:          res.Course.CourseID,
+		Name:        res.Course.CourseName,
+		Semester:    res.Course.Semester,
+		Description: &res.Course.Description,
+		CreatedAt:   now,
+		UpdatedAt:   now,
 	}
 
 	return course, nil
@@ -364,16 +420,11 @@ func (r *mutationResolver) UpdateCourse(ctx context.Context, id string, input mo
 
 	// Convert the response to GraphQL model
 	updatedCourse := &model.Course{
-		ID:            res.Course.CourseID,
-		Name:          res.Course.CourseName,
-		Semester:      res.Course.Semester,
-		Description:   &res.Course.Description,
-		UpdatedAt:     time.Now().Format(time.RFC3339),
-		Staff:         []*model.Staff{},
-		Students:      []*model.Student{},
-		Announcements: []*model.Announcement{},
-		Homework:      []*model.Homework{},
-		Grades:        []*model.Grade{},
+		ID:          res.Course.CourseID,
+		Name:        res.Course.CourseName,
+		Semester:    res.Course.Semester,
+		Description: &res.Course.Description,
+		UpdatedAt:   time.Now().Format(time.RFC3339),
 	}
 
 	return updatedCourse, nil
@@ -543,11 +594,40 @@ func (r *mutationResolver) CreateGrade(ctx context.Context, input model.NewGrade
 		UpdatedAt:  now,
 	}
 
+	// Notify the student
+	r.publishEvent(ctx, gradesTopic(grade.StudentID, grade.Semester), grade)
+
 	return grade, nil
 }
 
 // UpdateGrade is the resolver for the updateGrade field.
 func (r *mutationResolver) UpdateGrade(ctx context.Context, id string, input model.UpdateGrade) (*model.Grade, error) {
+	// Global IDs carry the ID given to the grade by the grades microservice
+	gradeID := id
+	key, hasKey := parseGradeKey(id)
+	if hasKey {
+		gradeID = key.GradeID
+	}
+
+	// Values left out keep their current value, which can only be looked up from a global ID
+	gradeValue, comments := input.GradeValue, input.Comments
+	if gradeValue == nil || comments == nil {
+		if !hasKey {
+			return nil, badUserInputError(ctx, "gradeValue and comments are required with grade IDs given by the grades microservice")
+		}
+
+		current, err := r.fetchGrade(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		if gradeValue == nil {
+			gradeValue = &current.GradeValue
+		}
+		if comments == nil {
+			comments = current.Comments
+		}
+	}
+
 	// Create an authenticated context with the token
 	authCtx := r.CreateAuthContext(ctx)
 
@@ -557,12 +637,14 @@ func (r *mutationResolver) UpdateGrade(ctx context.Context, id string, input mod
 	// Create the update request with just the fields to update
 	req := &gradespb.UpdateSingleGradeRequest{
 		Grade: &gradespb.SingleGrade{
-			GradeID:    id,
-			GradeValue: *input.GradeValue,
-			Comments:   *input.Comments,
+			GradeID:    gradeID,
+			GradeValue: *gradeValue,
 		},
 		Token: token,
 	}
+	if comments != nil {
+		req.Grade.Comments = *comments
+	}
 
 	// Call the grades microservice with the authenticated context
 	res, err := r.GradesClient.UpdateSingleGrade(authCtx, req)
@@ -584,11 +666,27 @@ func (r *mutationResolver) UpdateGrade(ctx context.Context, id string, input mod
 		UpdatedAt:  time.Now().Format(time.RFC3339),
 	}
 
+	// The grade keeps its ID, whatever the grades microservice echoes back
+	if hasKey {
+		key.identify(grade)
+	} else if grade.ID == "" {
+		grade.ID = id
+	}
+
+	// Notify the student
+	r.publishEvent(ctx, gradesTopic(grade.StudentID, grade.Semester), grade)
+
 	return grade, nil
 }
 
 // DeleteGrade is the resolver for the deleteGrade field.
-func (r *mutationResolver) DeleteGrade(ctx context.Context, id string, courseID string, semester string, studentID string, gradeType string, itemID string) (bool, error) {
+func (r *mutationResolver) DeleteGrade(ctx context.Context, id string, courseID *string, semester *string, studentID *string, gradeType *string, itemID *string) (bool, error) {
+	// Find the composite key the grades microservice deletes grades by
+	key, err := resolveGradeKey(ctx, id, courseID, semester, studentID, gradeType, itemID)
+	if err != nil {
+		return false, err
+	}
+
 	// Create an authenticated context with the token
 	authCtx := r.CreateAuthContext(ctx)
 
@@ -597,17 +695,17 @@ func (r *mutationResolver) DeleteGrade(ctx context.Context, id string, courseID
 
 	// Create the delete request
 	req := &gradespb.RemoveSingleGradeRequest{
-		GradeID:   id,
-		CourseID:  courseID,
-		Semester:  semester,
-		StudentID: studentID,
-		GradeType: gradeType,
-		ItemID:    itemID,
+		GradeID:   key.GradeID,
+		CourseID:  key.CourseID,
+		Semester:  key.Semester,
+		StudentID: key.StudentID,
+		GradeType: key.GradeType,
+		ItemID:    key.ItemID,
 		Token:     token,
 	}
 
 	// Call the grades microservice with the authenticated context
-	_, err := r.GradesClient.RemoveSingleGrade(authCtx, req)
+	_, err = r.GradesClient.RemoveSingleGrade(authCtx, req)
 	if err != nil {
 		return false, err
 	}
@@ -617,16 +715,53 @@ func (r *mutationResolver) DeleteGrade(ctx context.Context, id string, courseID
 
 // CreateHomework is the resolver for the createHomework field.
 func (r *mutationResolver) CreateHomework(ctx context.Context, input model.NewHomework) (*model.Homework, error) {
-	// Not implemented yet, but will need token authentication
-	_ = r.GetAuthTokenForRequest(ctx)
-	_ = r.CreateAuthContext(ctx)
-	return nil, fmt.Errorf("not implemented: CreateHomework - createHomework")
+	client, err := r.homeworkClient()
+	if err != nil {
+		return nil, err
+	}
+
+	// Call the homework microservice with the authenticated context
+	return client.CreateHomework(r.CreateAuthContext(ctx), input)
 }
 
 // SubmitHomework is the resolver for the submitHomework field.
-func (r *mutationResolver) SubmitHomework(ctx context.Context, homeworkID string, studentID string) (*model.Submission, error) {
-	// For now, return not implemented error - but using the token and auth context when implemented
-	return nil, fmt.Errorf("not implemented: SubmitHomework - submitHomework")
+func (r *mutationResolver) SubmitHomework(ctx context.Context, homeworkID string, studentID string, file graphql.Upload) (*model.Submission, error) {
+	client, err := r.homeworkClient()
+	if err != nil {
+		return nil, err
+	}
+
+	homework, err := r.loaders(ctx).Homework.Load(ctx, homeworkID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Students may only submit their own work, in courses they are enrolled in
+	if err := r.authorizeSubmission(ctx, studentID, homework.CourseID); err != nil {
+		return nil, err
+	}
+
+	// Stream the file to the blob store before recording the submission
+	object, err := r.storeSubmissionFile(ctx, homeworkID, studentID, file)
+	if err != nil {
+		return nil, err
+	}
+
+	// Call the homework microservice with the authenticated context
+	submission, err := client.SubmitHomework(r.CreateAuthContext(ctx), HomeworkSubmission{
+		HomeworkID:  homeworkID,
+		StudentID:   studentID,
+		Filename:    file.Filename,
+		ContentType: file.ContentType,
+		File:        object,
+	})
+	if err != nil {
+		// Don't keep files of submissions that were never recorded
+		r.discardSubmissionFile(ctx, object)
+		return nil, err
+	}
+
+	return submission, nil
 }
 
 // CreateAnnouncement is the resolver for the createAnnouncement field.
@@ -664,6 +799,9 @@ func (r *mutationResolver) CreateAnnouncement(ctx context.Context, input model.N
 		UpdatedAt: now,
 	}
 
+	// Notify the subscribers of the course
+	r.publishEvent(ctx, announcementsTopic(announcement.CourseID), announcement)
+
 	return announcement, nil
 }
 
@@ -675,6 +813,14 @@ func (r *mutationResolver) DeleteAnnouncement(ctx context.Context, courseID stri
 	// Get the token for the request
 	token := r.GetAuthTokenForRequest(ctx)
 
+	// Global IDs carry the course of the announcement
+	if key, ok := parseAnnouncementKey(announcementID); ok {
+		if key.CourseID != courseID {
+			return false, badUserInputError(ctx, "the announcement does not belong to this course")
+		}
+		announcementID = key.AnnouncementID
+	}
+
 	// Create a gRPC request to delete an announcement
 	req := &coursespb.RemoveAnnouncementRequest{
 		CourseID:       courseID,
@@ -691,376 +837,84 @@ func (r *mutationResolver) DeleteAnnouncement(ctx context.Context, courseID stri
 	return true, nil
 }
 
-// Student is the resolver for the student field.
-func (r *queryResolver) Student(ctx context.Context, id string) (*model.Student, error) {
-	// Create an authenticated context with the token
-	authCtx := r.CreateAuthContext(ctx)
-
-	// Get the token for the request
-	token := r.GetAuthTokenForRequest(ctx)
-
-	// Create a gRPC request to the students microservice
-	req := &studentspb.GetStudentRequest{
-		StudentID: id,
-		Token:     token, // Add token to request for backward compatibility
-	}
-
-	// Call the students microservice with the authenticated context
-	res, err := r.StudentsClient.GetStudent(authCtx, req)
-	if err != nil {
-		return nil, err
-	}
+// Node is the resolver for the node field.
+func (r *queryResolver) Node(ctx context.Context, id string) (model.Node, error) {
+	return r.fetchNode(ctx, id)
+}
 
-	// Convert the response to GraphQL model
-	student := &model.Student{
-		ID:          res.Student.StudentID,
-		FirstName:   res.Student.FirstName,
-		LastName:    res.Student.LastName,
-		Email:       res.Student.Email,
-		PhoneNumber: res.Student.PhoneNumber,
-		CreatedAt:   time.Now().Format(time.RFC3339), // In reality, these should come from the microservice
-		UpdatedAt:   time.Now().Format(time.RFC3339),
-		Courses:     []*model.Course{}, // We'll need to fetch courses separately
-	}
+// Nodes is the resolver for the nodes field.
+func (r *queryResolver) Nodes(ctx context.Context, ids []string) ([]model.Node, error) {
+	return r.fetchNodes(ctx, ids)
+}
 
-	return student, nil
+// Student is the resolver for the student field.
+func (r *queryResolver) Student(ctx context.Context, id string) (*model.Student, error) {
+	return r.fetchStudent(ctx, id)
 }
 
 // Staff is the resolver for the staff field.
 func (r *queryResolver) Staff(ctx context.Context, id string) (*model.Staff, error) {
-	// Create an authenticated context with the token
-	authCtx := r.CreateAuthContext(ctx)
-
-	// Get the token for the request
-	token := r.GetAuthTokenForRequest(ctx)
-
-	// Create a gRPC request to the staff microservice
-	req := &staffpb.GetStaffMemberRequest{
-		StaffID: id,
-		Token:   token,
-	}
-
-	// Call the staff microservice with the authenticated context
-	res, err := r.StaffClient.GetStaffMember(authCtx, req)
-	if err != nil {
-		return nil, err
-	}
-
-	// Convert the response to GraphQL model
-	staff := &model.Staff{
-		ID:          res.StaffMember.StaffID,
-		FirstName:   res.StaffMember.FirstName,
-		LastName:    res.StaffMember.LastName,
-		Email:       res.StaffMember.Email,
-		PhoneNumber: res.StaffMember.PhoneNumber,
-		Title:       &res.StaffMember.Title,
-		Office:      &res.StaffMember.Office,
-		CreatedAt:   time.Now().Format(time.RFC3339), // In reality, these should come from the microservice
-		UpdatedAt:   time.Now().Format(time.RFC3339),
-		Courses:     []*model.Course{}, // We'll need to fetch courses separately
-	}
-
-	return staff, nil
+	return r.fetchStaffMember(ctx, id)
 }
 
 // Course is the resolver for the course field.
 func (r *queryResolver) Course(ctx context.Context, id string) (*model.Course, error) {
-	// Create an authenticated context with the token
-	authCtx := r.CreateAuthContext(ctx)
-
-	// Get the token for the request
-	token := r.GetAuthTokenForRequest(ctx)
-
-	// Create a gRPC request to the courses microservice
-	req := &coursespb.GetCourseRequest{
-		CourseID: id,
-		Token:    token,
-	}
-
-	// Call the courses microservice with the authenticated context
-	res, err := r.CoursesClient.GetCourse(authCtx, req)
-	if err != nil {
-		return nil, err
-	}
-
-	// Convert the response to GraphQL model
-	course := &model.Course{
-		ID:            res.Course.CourseID,
-		Name:          res.Course.CourseName,
-		Semester:      res.Course.Semester,
-		Description:   &res.Course.Description,
-		CreatedAt:     time.Now().Format(time.RFC3339), // In reality, these should come from the microservice
-		UpdatedAt:     time.Now().Format(time.RFC3339),
-		Staff:         []*model.Staff{},
-		Students:      []*model.Student{},
-		Announcements: []*model.Announcement{},
-		Homework:      []*model.Homework{},
-		Grades:        []*model.Grade{},
-	}
-
-	return course, nil
+	return r.fetchCourse(ctx, id)
 }
 
 // CourseStudents is the resolver for the courseStudents field.
 func (r *queryResolver) CourseStudents(ctx context.Context, courseID string) ([]*model.Student, error) {
-	// Create an authenticated context with the token
-	authCtx := r.CreateAuthContext(ctx)
-
-	// Get the token for the request
-	token := r.GetAuthTokenForRequest(ctx)
-
-	// Create a gRPC request to the courses microservice to get student IDs
-	courseStudentsReq := &coursespb.GetCourseStudentsRequest{
-		CourseID: courseID,
-		Token:    token,
-	}
-
-	// Call the courses microservice with the authenticated context
-	courseStudentsRes, err := r.CoursesClient.GetCourseStudents(authCtx, courseStudentsReq)
-	if err != nil {
-		return nil, err
-	}
-
-	// Get the details for each student from the students microservice
-	students := make([]*model.Student, len(courseStudentsRes.StudentsIDs))
-	for i, studentID := range courseStudentsRes.StudentsIDs {
-		// Get student details
-		studentReq := &studentspb.GetStudentRequest{
-			StudentID: studentID,
-			Token:     token,
-		}
-
-		studentRes, err := r.StudentsClient.GetStudent(authCtx, studentReq)
-		if err != nil {
-			return nil, err
-		}
-
-		// Convert to GraphQL model
-		students[i] = &model.Student{
-			ID:          studentRes.Student.StudentID,
-			FirstName:   studentRes.Student.FirstName,
-			LastName:    studentRes.Student.LastName,
-			Email:       studentRes.Student.Email,
-			PhoneNumber: studentRes.Student.PhoneNumber,
-			CreatedAt:   time.Now().Format(time.RFC3339),
-			UpdatedAt:   time.Now().Format(time.RFC3339),
-			Courses:     []*model.Course{},
-		}
-	}
+	return r.fetchCourseStudents(ctx, courseID)
+}
 
-	return students, nil
+// CourseStudentsConnection is the resolver for the courseStudentsConnection field.
+func (r *queryResolver) CourseStudentsConnection(ctx context.Context, courseID string, first *int32, after *string, last *int32, before *string) (*model.StudentConnection, error) {
+	return r.fetchCourseStudentsConnection(ctx, courseID, pageArgs{first: first, after: after, last: last, before: before})
 }
 
 // CourseStaff is the resolver for the courseStaff field.
 func (r *queryResolver) CourseStaff(ctx context.Context, courseID string) ([]*model.Staff, error) {
-	// Create an authenticated context with the token
-	authCtx := r.CreateAuthContext(ctx)
-
-	// Get the token for the request
-	token := r.GetAuthTokenForRequest(ctx)
-
-	// Create a gRPC request to the courses microservice to get staff IDs
-	courseStaffReq := &coursespb.GetCourseStaffRequest{
-		CourseID: courseID,
-		Token:    token,
-	}
-
-	// Call the courses microservice with the authenticated context
-	courseStaffRes, err := r.CoursesClient.GetCourseStaff(authCtx, courseStaffReq)
-	if err != nil {
-		return nil, err
-	}
-
-	// Get the details for each staff member from the staff microservice
-	staffMembers := make([]*model.Staff, len(courseStaffRes.StaffIDs))
-	for i, staffID := range courseStaffRes.StaffIDs {
-		// Get staff details
-		staffReq := &staffpb.GetStaffMemberRequest{
-			StaffID: staffID,
-			Token:   token,
-		}
-
-		staffRes, err := r.StaffClient.GetStaffMember(authCtx, staffReq)
-		if err != nil {
-			return nil, err
-		}
-
-		// Convert to GraphQL model
-		staffMembers[i] = &model.Staff{
-			ID:          staffRes.StaffMember.StaffID,
-			FirstName:   staffRes.StaffMember.FirstName,
-			LastName:    staffRes.StaffMember.LastName,
-			Email:       staffRes.StaffMember.Email,
-			PhoneNumber: staffRes.StaffMember.PhoneNumber,
-			Title:       &staffRes.StaffMember.Title,
-			Office:      &staffRes.StaffMember.Office,
-			CreatedAt:   time.Now().Format(time.RFC3339),
-			UpdatedAt:   time.Now().Format(time.RFC3339),
-			Courses:     []*model.Course{},
-		}
-	}
-
-	return staffMembers, nil
+	return r.fetchCourseStaff(ctx, courseID)
 }
 
 // StudentCourses is the resolver for the studentCourses field.
 func (r *queryResolver) StudentCourses(ctx context.Context, studentID string) ([]*model.Course, error) {
-	// Create an authenticated context with the token
-	authCtx := r.CreateAuthContext(ctx)
-
-	// Get the token for the request
-	token := r.GetAuthTokenForRequest(ctx)
-
-	// Create a gRPC request to the courses microservice to get course IDs for a student
-	studentCoursesReq := &coursespb.GetStudentCoursesRequest{
-		StudentID: studentID,
-		Token:     token,
-	}
-
-	// Call the courses microservice with the authenticated context
-	studentCoursesRes, err := r.CoursesClient.GetStudentCourses(authCtx, studentCoursesReq)
-	if err != nil {
-		return nil, err
-	}
-
-	// Get the details for each course
-	courses := make([]*model.Course, len(studentCoursesRes.CoursesIDs))
-	for i, courseID := range studentCoursesRes.CoursesIDs {
-		// Get course details
-		courseReq := &coursespb.GetCourseRequest{
-			CourseID: courseID,
-			Token:    token,
-		}
-
-		courseRes, err := r.CoursesClient.GetCourse(authCtx, courseReq)
-		if err != nil {
-			return nil, err
-		}
-
-		// Convert to GraphQL model
-		courses[i] = &model.Course{
-			ID:            courseRes.Course.CourseID,
-			Name:          courseRes.Course.CourseName,
-			Semester:      courseRes.Course.Semester,
-			Description:   &courseRes.Course.Description,
-			CreatedAt:     time.Now().Format(time.RFC3339),
-			UpdatedAt:     time.Now().Format(time.RFC3339),
-			Staff:         []*model.Staff{},
-			Students:      []*model.Student{},
-			Announcements: []*model.Announcement{},
-			Homework:      []*model.Homework{},
-			Grades:        []*model.Grade{},
-		}
-	}
-
-	return courses, nil
+	return r.fetchStudentCourses(ctx, studentID)
 }
 
 // StaffCourses is the resolver for the staffCourses field.
 func (r *queryResolver) StaffCourses(ctx context.Context, staffID string) ([]*model.Course, error) {
-	// Create an authenticated context with the token
-	authCtx := r.CreateAuthContext(ctx)
-
-	// Get the token for the request
-	token := r.GetAuthTokenForRequest(ctx)
-
-	// Create a gRPC request to the courses microservice to get course IDs for a staff member
-	staffCoursesReq := &coursespb.GetStaffCoursesRequest{
-		StaffID: staffID,
-		Token:   token,
-	}
-
-	// Call the courses microservice with the authenticated context
-	staffCoursesRes, err := r.CoursesClient.GetStaffCourses(authCtx, staffCoursesReq)
-	if err != nil {
-		return nil, err
-	}
-
-	// Get the details for each course
-	courses := make([]*model.Course, len(staffCoursesRes.CoursesIDs))
-	for i, courseID := range staffCoursesRes.CoursesIDs {
-		// Get course details
-		courseReq := &coursespb.GetCourseRequest{
-			CourseID: courseID,
-			Token:    token,
-		}
-
-		courseRes, err := r.CoursesClient.GetCourse(authCtx, courseReq)
-		if err != nil {
-			return nil, err
-		}
-
-		// Convert to GraphQL model
-		courses[i] = &model.Course{
-			ID:            courseRes.Course.CourseID,
-			Name:          courseRes.Course.CourseName,
-			Semester:      courseRes.Course.Semester,
-			Description:   &courseRes.Course.Description,
-			CreatedAt:     time.Now().Format(time.RFC3339),
-			UpdatedAt:     time.Now().Format(time.RFC3339),
-			Staff:         []*model.Staff{},
-			Students:      []*model.Student{},
-			Announcements: []*model.Announcement{},
-			Homework:      []*model.Homework{},
-			Grades:        []*model.Grade{},
-		}
-	}
-
-	return courses, nil
+	return r.fetchStaffCourses(ctx, staffID)
 }
 
 // SemesterCourses is the resolver for the semesterCourses field.
 func (r *queryResolver) SemesterCourses(ctx context.Context, semester string) ([]*model.Course, error) {
-	// Create an authenticated context with the token
-	authCtx := r.CreateAuthContext(ctx)
-
-	// Get the token for the request
-	token := r.GetAuthTokenForRequest(ctx)
-
-	// Create a gRPC request to the courses microservice
-	req := &coursespb.GetSemesterCoursesRequest{
-		Semester: semester,
-		Token:    token,
-	}
+	return r.fetchSemesterCourses(ctx, semester)
+}
 
-	// Call the courses microservice with the authenticated context
-	res, err := r.CoursesClient.GetSemesterCourses(authCtx, req)
+// SemesterCoursesConnection is the resolver for the semesterCoursesConnection field.
+func (r *queryResolver) SemesterCoursesConnection(ctx context.Context, semester string, first *int32, after *string, last *int32, before *string) (*model.CourseConnection, error) {
+	courses, err := r.fetchSemesterCourses(ctx, semester)
 	if err != nil {
 		return nil, err
 	}
 
-	// Convert the response to GraphQL model
-	courses := make([]*model.Course, len(res.Courses))
-	for i, c := range res.Courses {
-		courses[i] = &model.Course{
-			ID:            c.CourseID,
-			Name:          c.CourseName,
-			Semester:      c.Semester,
-			Description:   &c.Description,
-			CreatedAt:     time.Now().Format(time.RFC3339), // These should come from the microservice
-			UpdatedAt:     time.Now().Format(time.RFC3339),
-			Staff:         []*model.Staff{},
-			Students:      []*model.Student{},
-			Announcements: []*model.Announcement{},
-			Homework:      []*model.Homework{},
-			Grades:        []*model.Grade{},
-		}
-	}
-
-	return courses, nil
+	return newCourseConnection(ctx, courses, pageArgs{first: first, after: after, last: last, before: before})
 }
 
 // Grade is the resolver for the grade field.
 func (r *queryResolver) Grade(ctx context.Context, id string) (*model.Grade, error) {
-	// We don't have a direct GetGrade method in the proto
-	// We would need to implement this in the grades microservice or
-	// use an alternative approach like filtering the results of GetCourseGrades
-	// For this implementation, we'll leave this unimplemented
-	return nil, fmt.Errorf("not fully implemented: need a GetGrade method in the grades microservice")
+	// The ID of a grade carries the key it is looked up by
+	key, ok := parseGradeKey(id)
+	if !ok {
+		return nil, badUserInputError(ctx, "grades can only be looked up by the id of a Grade")
+	}
+
+	return r.fetchGrade(ctx, key)
 }
 
 // Grades is the resolver for the grades field.
-func (r *queryResolver) Grades(ctx context.Context, studentID *string, courseID *string) ([]*model.Grade, error) {
+func (r *queryResolver) Grades(ctx context.Context, studentID *string, courseID *string, filter *model.GradeFilter, orderBy *model.GradeOrder) ([]*model.Grade, error) {
 	// Create an authenticated context with the token
 	authCtx := r.CreateAuthContext(ctx)
 
@@ -1071,21 +925,20 @@ func (r *queryResolver) Grades(ctx context.Context, studentID *string, courseID
 	// For now, we'll implement a simple approach that returns course grades if courseID is provided
 	// Otherwise, we'll return student semester grades if studentID is provided
 
+	var grades []*model.Grade
 	if courseID != nil {
-		// Get all grades for a course
-		req := &gradespb.GetCourseGradesRequest{
-			CourseID: *courseID,
-			Semester: time.Now().Format("2006-01"), // Current semester, format as needed
-			Token:    token,                        // Add token to request for backward compatibility
-		}
-
-		res, err := r.GradesClient.GetCourseGrades(authCtx, req)
+		// Get all grades for a course in the current semester, format as needed
+		courseGrades, err := r.fetchCourseGrades(ctx, *courseID, time.Now().Format("2006-01"))
 		if err != nil {
 			return nil, err
 		}
-
-		return convertGradesToGraphQL(res.Grades), nil
+		grades = courseGrades
 	} else if studentID != nil {
+		// Check the caller may read this student's grades
+		if err := r.authorizeStudentRecord(ctx, *studentID); err != nil {
+			return nil, err
+		}
+
 		// Get all grades for a student across all semesters by using an empty semester
 		// This will be handled by the grades service to return all grades
 		req := &gradespb.GetStudentSemesterGradesRequest{
@@ -1099,38 +952,51 @@ func (r *queryResolver) Grades(ctx context.Context, studentID *string, courseID
 			return nil, err
 		}
 
-		return convertGradesToGraphQL(res.Grades), nil
+		// Staff only see the grades of the courses they are assigned to
+		grades, err = r.filterVisibleGrades(ctx, convertGradesToGraphQL(res.Grades))
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		return nil, badUserInputError(ctx, "either studentId or courseId must be provided")
 	}
 
-	return nil, fmt.Errorf("either studentID or courseID must be provided")
+	return applyGradeQuery(ctx, grades, filter, orderBy)
 }
 
 // CourseGrades is the resolver for the courseGrades field.
-func (r *queryResolver) CourseGrades(ctx context.Context, courseID string, semester string) ([]*model.Grade, error) {
-	// Create an authenticated context with the token
-	authCtx := r.CreateAuthContext(ctx)
+func (r *queryResolver) CourseGrades(ctx context.Context, courseID string, semester string, filter *model.GradeFilter, orderBy *model.GradeOrder) ([]*model.Grade, error) {
+	grades, err := r.fetchCourseGrades(ctx, courseID, semester)
+	if err != nil {
+		return nil, err
+	}
 
-	// Get the token for the request
-	token := r.GetAuthTokenForRequest(ctx)
+	return applyGradeQuery(ctx, grades, filter, orderBy)
+}
 
-	// Get all grades for a course in a specific semester
-	req := &gradespb.GetCourseGradesRequest{
-		CourseID: courseID,
-		Semester: semester,
-		Token:    token,
+// CourseGradesConnection is the resolver for the courseGradesConnection field.
+func (r *queryResolver) CourseGradesConnection(ctx context.Context, courseID string, semester string, filter *model.GradeFilter, orderBy *model.GradeOrder, first *int32, after *string, last *int32, before *string) (*model.GradeConnection, error) {
+	grades, err := r.fetchCourseGrades(ctx, courseID, semester)
+	if err != nil {
+		return nil, err
 	}
 
-	// Call the grades microservice with the authenticated context
-	res, err := r.GradesClient.GetCourseGrades(authCtx, req)
+	// Filter and sort before paginating, so that cursors point into the requested list
+	grades, err = applyGradeQuery(ctx, grades, filter, orderBy)
 	if err != nil {
 		return nil, err
 	}
 
-	return convertGradesToGraphQL(res.Grades), nil
+	return newGradeConnection(ctx, grades, pageArgs{first: first, after: after, last: last, before: before})
 }
 
 // StudentCourseGrades is the resolver for the studentCourseGrades field.
-func (r *queryResolver) StudentCourseGrades(ctx context.Context, studentID string, courseID string, semester string) ([]*model.Grade, error) {
+func (r *queryResolver) StudentCourseGrades(ctx context.Context, studentID string, courseID string, semester string, filter *model.GradeFilter, orderBy *model.GradeOrder) ([]*model.Grade, error) {
+	// Check the caller may read this student's grades in the course
+	if err := r.authorizeStudentCourseRecord(ctx, studentID, courseID); err != nil {
+		return nil, err
+	}
+
 	// Create an authenticated context with the token
 	authCtx := r.CreateAuthContext(ctx)
 
@@ -1151,11 +1017,16 @@ func (r *queryResolver) StudentCourseGrades(ctx context.Context, studentID strin
 		return nil, err
 	}
 
-	return convertGradesToGraphQL(res.Grades), nil
+	return applyGradeQuery(ctx, convertGradesToGraphQL(res.Grades), filter, orderBy)
 }
 
 // StudentSemesterGrades is the resolver for the studentSemesterGrades field.
-func (r *queryResolver) StudentSemesterGrades(ctx context.Context, studentID string, semester string) ([]*model.Grade, error) {
+func (r *queryResolver) StudentSemesterGrades(ctx context.Context, studentID string, semester string, filter *model.GradeFilter, orderBy *model.GradeOrder) ([]*model.Grade, error) {
+	// Check the caller may read this student's grades
+	if err := r.authorizeStudentRecord(ctx, studentID); err != nil {
+		return nil, err
+	}
+
 	// Create an authenticated context with the token
 	authCtx := r.CreateAuthContext(ctx)
 
@@ -1175,92 +1046,200 @@ func (r *queryResolver) StudentSemesterGrades(ctx context.Context, studentID str
 		return nil, err
 	}
 
-	return convertGradesToGraphQL(res.Grades), nil
+	// Staff only see the grades of the courses they are assigned to
+	grades, err := r.filterVisibleGrades(ctx, convertGradesToGraphQL(res.Grades))
+	if err != nil {
+		return nil, err
+	}
+
+	return applyGradeQuery(ctx, grades, filter, orderBy)
 }
 
 // Homework is the resolver for the homework field.
 func (r *queryResolver) Homework(ctx context.Context, id string) (*model.Homework, error) {
-	// Not implemented yet, but will need token authentication
-	_ = r.GetAuthTokenForRequest(ctx)
-	_ = r.CreateAuthContext(ctx)
-	return nil, fmt.Errorf("not implemented: Homework - homework")
+	return r.fetchHomework(ctx, id)
 }
 
 // HomeworkByCourse is the resolver for the homeworkByCourse field.
 func (r *queryResolver) HomeworkByCourse(ctx context.Context, courseID string) ([]*model.Homework, error) {
-	// Not implemented yet, but will need token authentication
-	_ = r.GetAuthTokenForRequest(ctx)
-	_ = r.CreateAuthContext(ctx)
-	return nil, fmt.Errorf("not implemented: HomeworkByCourse - homeworkByCourse")
+	return r.fetchCourseHomework(ctx, courseID)
 }
 
 // Submission is the resolver for the submission field.
 func (r *queryResolver) Submission(ctx context.Context, id string) (*model.Submission, error) {
-	// Not implemented yet, but will need token authentication
-	_ = r.GetAuthTokenForRequest(ctx)
-	_ = r.CreateAuthContext(ctx)
-	return nil, fmt.Errorf("not implemented: Submission - submission")
+	client, err := r.homeworkClient()
+	if err != nil {
+		return nil, err
+	}
+
+	// Call the homework microservice with the authenticated context
+	submission, err := client.GetSubmission(r.CreateAuthContext(ctx), id)
+	if err != nil {
+		return nil, err
+	}
+
+	homework, err := r.loaders(ctx).Homework.Load(ctx, submission.HomeworkID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the caller may read this student's records in the homework's course
+	if err := r.authorizeStudentCourseRecord(ctx, submission.StudentID, homework.CourseID); err != nil {
+		return nil, err
+	}
+
+	return submission, nil
 }
 
 // SubmissionsByStudent is the resolver for the submissionsByStudent field.
 func (r *queryResolver) SubmissionsByStudent(ctx context.Context, studentID string) ([]*model.Submission, error) {
-	// Not implemented yet, but will need token authentication
-	_ = r.GetAuthTokenForRequest(ctx)
-	_ = r.CreateAuthContext(ctx)
-	return nil, fmt.Errorf("not implemented: SubmissionsByStudent - submissionsByStudent")
+	// Check the caller may read this student's records
+	if err := r.authorizeStudentRecord(ctx, studentID); err != nil {
+		return nil, err
+	}
+
+	client, err := r.homeworkClient()
+	if err != nil {
+		return nil, err
+	}
+
+	// Call the homework microservice with the authenticated context
+	submissions, err := client.GetStudentSubmissions(r.CreateAuthContext(ctx), studentID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Staff only see the submissions of the courses they are assigned to
+	return r.filterVisibleSubmissions(ctx, submissions)
 }
 
 // Announcement is the resolver for the announcement field.
 func (r *queryResolver) Announcement(ctx context.Context, id string) (*model.Announcement, error) {
-	// Not implemented yet, but will need token authentication
-	_ = r.GetAuthTokenForRequest(ctx)
-	_ = r.CreateAuthContext(ctx)
-	return nil, fmt.Errorf("not implemented: Announcement - announcement")
+	// The ID of an announcement carries its course
+	key, ok := parseAnnouncementKey(id)
+	if !ok {
+		return nil, badUserInputError(ctx, "announcements can only be looked up by the id of an Announcement")
+	}
+
+	return r.fetchAnnouncement(ctx, key)
 }
 
 // AnnouncementsByCourse is the resolver for the announcementsByCourse field.
 func (r *queryResolver) AnnouncementsByCourse(ctx context.Context, courseID string) ([]*model.Announcement, error) {
-	// Create an authenticated context with the token
-	authCtx := r.CreateAuthContext(ctx)
-
-	// Get the token for the request
-	token := r.GetAuthTokenForRequest(ctx)
+	return r.fetchCourseAnnouncements(ctx, courseID)
+}
 
-	// Create a gRPC request to get course announcements
-	req := &coursespb.GetCourseAnnouncementsRequest{
-		CourseID: courseID,
-		Token:    token, // Add token to request for backward compatibility
+// AnnouncementsByCourseConnection is the resolver for the announcementsByCourseConnection field.
+func (r *queryResolver) AnnouncementsByCourseConnection(ctx context.Context, courseID string, first *int32, after *string, last *int32, before *string) (*model.AnnouncementConnection, error) {
+	announcements, err := r.fetchCourseAnnouncements(ctx, courseID)
+	if err != nil {
+		return nil, err
 	}
 
-	// Call the courses microservice with the authenticated context
-	res, err := r.CoursesClient.GetCourseAnnouncements(authCtx, req)
-	if err != nil {
+	return newAnnouncementConnection(ctx, announcements, pageArgs{first: first, after: after, last: last, before: before})
+}
+
+// ID is the resolver for the id field.
+func (r *staffResolver) ID(ctx context.Context, obj *model.Staff) (string, error) {
+	return globalID(nodeTypeStaff, obj.ID), nil
+}
+
+// Courses is the resolver for the courses field.
+func (r *staffResolver) Courses(ctx context.Context, obj *model.Staff) ([]*model.Course, error) {
+	return r.fetchStaffCourses(ctx, obj.ID)
+}
+
+// ID is the resolver for the id field.
+func (r *studentResolver) ID(ctx context.Context, obj *model.Student) (string, error) {
+	return globalID(nodeTypeStudent, obj.ID), nil
+}
+
+// Courses is the resolver for the courses field.
+func (r *studentResolver) Courses(ctx context.Context, obj *model.Student) ([]*model.Course, error) {
+	return r.fetchStudentCourses(ctx, obj.ID)
+}
+
+// ID is the resolver for the id field.
+func (r *submissionResolver) ID(ctx context.Context, obj *model.Submission) (string, error) {
+	return globalID(nodeTypeSubmission, obj.ID), nil
+}
+
+// HomeworkID is the resolver for the homeworkId field.
+func (r *submissionResolver) HomeworkID(ctx context.Context, obj *model.Submission) (string, error) {
+	return globalID(nodeTypeHomework, obj.HomeworkID), nil
+}
+
+// StudentID is the resolver for the studentId field.
+func (r *submissionResolver) StudentID(ctx context.Context, obj *model.Submission) (string, error) {
+	return globalID(nodeTypeStudent, obj.StudentID), nil
+}
+
+// AnnouncementAdded is the resolver for the announcementAdded field.
+func (r *subscriptionResolver) AnnouncementAdded(ctx context.Context, courseID string) (<-chan *model.Announcement, error) {
+	// Only members of the course may follow its announcements
+	if _, err := r.courseRecordsScope(ctx, courseID); err != nil {
 		return nil, err
 	}
 
-	// Convert the response to GraphQL model
-	announcements := make([]*model.Announcement, len(res.Announcements))
-	for i, a := range res.Announcements {
-		announcements[i] = &model.Announcement{
-			ID:        a.AnnouncementID,
-			CourseID:  courseID,
-			Title:     a.AnnouncementTitle,
-			Content:   a.AnnouncementContent,
-			CreatedAt: time.Now().Format(time.RFC3339), // These should come from the microservice
-			UpdatedAt: time.Now().Format(time.RFC3339),
-		}
+	return subscribeEvents[model.Announcement](ctx, r.PubSub, announcementsTopic(courseID), nil)
+}
+
+// GradePublished is the resolver for the gradePublished field.
+func (r *subscriptionResolver) GradePublished(ctx context.Context, studentID string, semester string) (<-chan *model.Grade, error) {
+	// Check the caller may read this student's grades
+	if err := r.authorizeStudentRecord(ctx, studentID); err != nil {
+		return nil, err
 	}
 
-	return announcements, nil
+	// Staff only receive the grades of the courses they are assigned to
+	return subscribeEvents(ctx, r.PubSub, gradesTopic(studentID, semester), func(g *model.Grade) bool {
+		visible, err := r.gradeVisible(ctx, GetClaims(ctx), g)
+		if err != nil {
+			LoggerFromContext(ctx).Error("failed to authorize grade event", "error", err)
+		}
+		return visible
+	})
 }
 
+// Announcement returns AnnouncementResolver implementation.
+func (r *Resolver) Announcement() AnnouncementResolver { return &announcementResolver{r} }
+
+// Course returns CourseResolver implementation.
+func (r *Resolver) Course() CourseResolver { return &courseResolver{r} }
+
+// Grade returns GradeResolver implementation.
+func (r *Resolver) Grade() GradeResolver { return &gradeResolver{r} }
+
+// Homework returns HomeworkResolver implementation.
+func (r *Resolver) Homework() HomeworkResolver { return &homeworkResolver{r} }
+
 // Mutation returns MutationResolver implementation.
 func (r *Resolver) Mutation() MutationResolver { return &mutationResolver{r} }
 
 // Query returns QueryResolver implementation.
 func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
 
+// Staff returns StaffResolver implementation.
+func (r *Resolver) Staff() StaffResolver { return &staffResolver{r} }
+
+// Student returns StudentResolver implementation.
+func (r *Resolver) Student() StudentResolver { return &studentResolver{r} }
+
+// Submission returns SubmissionResolver implementation.
+func (r *Resolver) Submission() SubmissionResolver { return &submissionResolver{r} }
+
+// Subscription returns SubscriptionResolver implementation.
+func (r *Resolver) Subscription() SubscriptionResolver { return &subscriptionResolver{r} }
+
 type (
-	mutationResolver struct{ *Resolver }
-	queryResolver    struct{ *Resolver }
+	announcementResolver struct{ *Resolver }
+	courseResolver       struct{ *Resolver }
+	gradeResolver        struct{ *Resolver }
+	homeworkResolver     struct{ *Resolver }
+	mutationResolver     struct{ *Resolver }
+	queryResolver        struct{ *Resolver }
+	staffResolver        struct{ *Resolver }
+	studentResolver      struct{ *Resolver }
+	submissionResolver   struct{ *Resolver }
+	subscriptionResolver struct{ *Resolver }
 )
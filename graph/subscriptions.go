@@ -0,0 +1,74 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/BetterGR/api-gateway/graph/pubsub"
+)
+
+// announcementsTopic is the topic announcements of a course are published on
+func announcementsTopic(courseID string) string {
+	return "announcements." + courseID
+}
+
+// gradesTopic is the topic a student's grades for a semester are published on
+func gradesTopic(studentID, semester string) string {
+	return "grades." + studentID + "." + semester
+}
+
+// publishEvent encodes event and publishes it on topic. Failing to notify subscribers must
+// not fail the mutation that triggered the event, so errors are only logged.
+func (r *Resolver) publishEvent(ctx context.Context, topic string, event any) {
+	if r.PubSub == nil {
+		return
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		r.logger(ctx).Error("failed to encode event", "topic", topic, "error", err)
+		return
+	}
+
+	if err := r.PubSub.Publish(ctx, topic, payload); err != nil {
+		r.logger(ctx).Error("failed to publish event", "topic", topic, "error", err)
+	}
+}
+
+// subscribeEvents subscribes to topic and decodes the events published on it into values of type T.
+// When visible is not nil, only the events it accepts are delivered.
+func subscribeEvents[T any](ctx context.Context, bus pubsub.Bus, topic string, visible func(*T) bool) (<-chan *T, error) {
+	if bus == nil {
+		return nil, errors.New("subscriptions are not available")
+	}
+
+	payloads, err := bus.Subscribe(ctx, topic)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan *T)
+	go func() {
+		defer close(events)
+
+		for payload := range payloads {
+			event := new(T)
+			if err := json.Unmarshal(payload, event); err != nil {
+				LoggerFromContext(ctx).Error("failed to decode event", "topic", topic, "error", err)
+				continue
+			}
+			if visible != nil && !visible(event) {
+				continue
+			}
+
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return events, nil
+}
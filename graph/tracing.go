@@ -0,0 +1,285 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.39.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// tracerName identifies the spans created by the gateway
+const tracerName = "github.com/BetterGR/api-gateway/graph"
+
+// FieldTracing selects which resolved fields get a span of their own
+type FieldTracing string
+
+const (
+	// TraceNoFields only traces operations and the calls to the microservices
+	TraceNoFields FieldTracing = "none"
+	// TraceResolverFields traces the fields that have a resolver, skipping the fields that are
+	// read from an already fetched object
+	TraceResolverFields FieldTracing = "resolvers"
+	// TraceAllFields traces every field, which is mostly useful when debugging
+	TraceAllFields FieldTracing = "all"
+)
+
+// TracingSettings configures how spans are exported
+type TracingSettings struct {
+	// Exporter is where spans are sent: none, stdout or otlp
+	Exporter string
+	// Fields selects which resolved fields are traced
+	Fields FieldTracing
+}
+
+// LoadTracingSettings builds the tracing settings from the environment. The OTLP exporter is
+// configured through the standard OTEL_EXPORTER_OTLP_* variables, and the service name through
+// OTEL_SERVICE_NAME.
+func LoadTracingSettings() (TracingSettings, error) {
+	settings := TracingSettings{
+		Exporter: strings.ToLower(getEnvOrDefault("OTEL_TRACES_EXPORTER", "none")),
+		Fields:   FieldTracing(strings.ToLower(getEnvOrDefault("GRAPHQL_TRACE_FIELDS", string(TraceResolverFields)))),
+	}
+
+	switch settings.Exporter {
+	case "none", "stdout", "otlp":
+	default:
+		return TracingSettings{}, fmt.Errorf("OTEL_TRACES_EXPORTER must be none, stdout or otlp, got %q", settings.Exporter)
+	}
+
+	switch settings.Fields {
+	case TraceNoFields, TraceResolverFields, TraceAllFields:
+	default:
+		return TracingSettings{}, fmt.Errorf("GRAPHQL_TRACE_FIELDS must be none, resolvers or all, got %q", settings.Fields)
+	}
+
+	return settings, nil
+}
+
+// SetupTracing installs the global tracer provider and the W3C trace context propagator. The
+// returned function flushes the spans still buffered and must be called on shutdown. Incoming
+// trace context is propagated even when spans are not exported, so that the microservices can
+// still join the traces of their callers.
+func SetupTracing(ctx context.Context, settings TracingSettings) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch settings.Exporter {
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case "otlp":
+		exporter, err = otlptracegrpc.New(ctx)
+	default:
+		return func(context.Context) error { return nil }, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the %s trace exporter: %w", settings.Exporter, err)
+	}
+
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES take precedence over the default name
+	res, err := resource.New(ctx,
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(semconv.ServiceName("api-gateway")),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe the trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// TraceContextMiddleware continues the trace of the client when the request carries a
+// traceparent header
+func TraceContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Tracing is a gqlgen extension creating a span for every operation and, depending on its
+// settings, for the fields resolved while executing it. The calls made to the microservices
+// become children of the span of the field that made them.
+type Tracing struct {
+	fields FieldTracing
+}
+
+var (
+	_ graphql.HandlerExtension    = (*Tracing)(nil)
+	_ graphql.ResponseInterceptor = (*Tracing)(nil)
+	_ graphql.FieldInterceptor    = (*Tracing)(nil)
+)
+
+// NewTracing creates the tracing extension
+func NewTracing(fields FieldTracing) *Tracing {
+	return &Tracing{fields: fields}
+}
+
+// ExtensionName identifies the extension to gqlgen
+func (t *Tracing) ExtensionName() string {
+	return "Tracing"
+}
+
+// Validate is called by gqlgen when the extension is registered
+func (t *Tracing) Validate(graphql.ExecutableSchema) error {
+	return nil
+}
+
+// InterceptResponse wraps the execution of an operation in a span. Subscriptions get a span
+// for every event they deliver.
+func (t *Tracing) InterceptResponse(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+	oc := graphql.GetOperationContext(ctx)
+	if oc.Operation == nil {
+		return next(ctx)
+	}
+
+	name := string(oc.Operation.Operation)
+	if oc.Operation.Name != "" {
+		name += " " + oc.Operation.Name
+	}
+
+	ctx, span := otel.Tracer(tracerName).Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			semconv.GraphQLOperationName(oc.Operation.Name),
+			semconv.GraphQLOperationTypeKey.String(string(oc.Operation.Operation)),
+		),
+	)
+	defer span.End()
+
+	res := next(ctx)
+	if res != nil && len(res.Errors) > 0 {
+		span.SetAttributes(attribute.Int("graphql.errors.count", len(res.Errors)))
+		span.SetStatus(otelcodes.Error, res.Errors[0].Message)
+	}
+
+	return res
+}
+
+// InterceptField wraps the resolution of a field in a span
+func (t *Tracing) InterceptField(ctx context.Context, next graphql.Resolver) (any, error) {
+	fc := graphql.GetFieldContext(ctx)
+	if !t.traces(fc) {
+		return next(ctx)
+	}
+
+	ctx, span := otel.Tracer(tracerName).Start(ctx, fc.Object+"."+fc.Field.Name,
+		trace.WithAttributes(
+			attribute.String("graphql.field.name", fc.Field.Name),
+			attribute.String("graphql.field.path", fc.Path().String()),
+			attribute.String("graphql.field.type", fc.Field.Definition.Type.String()),
+		),
+	)
+	defer span.End()
+
+	res, err := next(ctx)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(otelcodes.Error, err.Error())
+	}
+
+	return res, err
+}
+
+// traces reports whether a field gets a span of its own. Introspection fields never do.
+func (t *Tracing) traces(fc *graphql.FieldContext) bool {
+	if fc == nil || fc.Field.Field == nil || fc.Field.Definition == nil ||
+		strings.HasPrefix(fc.Object, "__") || strings.HasPrefix(fc.Field.Name, "__") {
+		return false
+	}
+
+	switch t.fields {
+	case TraceAllFields:
+		return true
+	case TraceResolverFields:
+		// Resolvers of fields of type ID only encode global IDs
+		return fc.IsResolver && fc.Field.Definition.Type.Name() != "ID"
+	default:
+		return false
+	}
+}
+
+// tracingInterceptor creates a client span for every call made to a microservice and
+// propagates its trace context in the outgoing metadata, next to the authorization header
+// set by CreateAuthContext
+func tracingInterceptor(service string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		fullMethod := strings.TrimPrefix(method, "/")
+		ctx, span := otel.Tracer(tracerName).Start(ctx, fullMethod,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				semconv.RPCSystemNameGRPC,
+				semconv.RPCMethod(fullMethod),
+				attribute.String("gateway.backend", service),
+			),
+		)
+		defer span.End()
+
+		// Add the trace context to a copy of the metadata, keeping the authorization header
+		md, _ := metadata.FromOutgoingContext(ctx)
+		md = md.Copy()
+		otel.GetTextMapPropagator().Inject(ctx, metadataCarrier(md))
+		ctx = metadata.NewOutgoingContext(ctx, md)
+
+		err := invoker(ctx, method, req, reply, cc, opts...)
+
+		span.SetAttributes(semconv.RPCResponseStatusCode(status.Code(err).String()))
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(otelcodes.Error, err.Error())
+		}
+
+		return err
+	}
+}
+
+// metadataCarrier lets the propagator write the trace context into gRPC metadata
+type metadataCarrier metadata.MD
+
+// Get returns the first value of key
+func (c metadataCarrier) Get(key string) string {
+	values := metadata.MD(c).Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// Set replaces the values of key
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+// Keys lists the keys present in the metadata
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
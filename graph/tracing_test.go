@@ -0,0 +1,189 @@
+package graph
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/BetterGR/api-gateway/graph/model"
+	studentspb "github.com/BetterGR/students-microservice/protos"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// recordSpans installs a tracer provider recording the spans ended during the test
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+
+	return recorder
+}
+
+// spanNamed returns the ended span with the given name
+func spanNamed(t *testing.T, recorder *tracetest.SpanRecorder, name string) sdktrace.ReadOnlySpan {
+	t.Helper()
+
+	var names []string
+	for _, span := range recorder.Ended() {
+		if span.Name() == name {
+			return span
+		}
+		names = append(names, span.Name())
+	}
+
+	t.Fatalf("no span named %q, got %v", name, names)
+	return nil
+}
+
+// tracedStudents is a students microservice recording the metadata of the calls it receives
+type tracedStudents struct {
+	studentspb.UnimplementedStudentsServiceServer
+
+	mu sync.Mutex
+	md metadata.MD
+}
+
+func (s *tracedStudents) GetStudent(ctx context.Context, in *studentspb.GetStudentRequest) (*studentspb.GetStudentResponse, error) {
+	s.mu.Lock()
+	s.md, _ = metadata.FromIncomingContext(ctx)
+	s.mu.Unlock()
+
+	return &studentspb.GetStudentResponse{Student: &studentspb.Student{StudentID: in.StudentID, FirstName: "Ada"}}, nil
+}
+
+// tracedServer returns a gateway whose students microservice is reached over gRPC through the
+// tracing interceptor
+func tracedServer(t *testing.T, fields FieldTracing) (http.Handler, *tracedStudents) {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	students := &tracedStudents{}
+	srv := grpc.NewServer()
+	studentspb.RegisterStudentsServiceServer(srv, students)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	r := newFakeSchool().resolver()
+	r.StudentsClient = studentspb.NewStudentsServiceClient(
+		dialFake(t, lis.Addr().String(), grpc.WithUnaryInterceptor(tracingInterceptor("students"))),
+	)
+
+	gql := newTestServer(r)
+	gql.Use(NewTracing(fields))
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ctx := context.WithValue(req.Context(), AuthTokenKey, "token")
+		gql.ServeHTTP(w, req.WithContext(ctx))
+	})
+
+	return TraceContextMiddleware(withClaims("s1", []string{model.RoleStudent.String()}, h)), students
+}
+
+func TestTracingSpansFromOperationToGRPCCall(t *testing.T) {
+	recorder := recordSpans(t)
+	h, students := tracedServer(t, TraceResolverFields)
+
+	res := execute(t, h, `query Profile { student(id: "s1") { firstName } }`, nil)
+	if len(res.Errors) > 0 {
+		t.Fatalf("unexpected errors %v", res.Errors)
+	}
+
+	operation := spanNamed(t, recorder, "query Profile")
+	field := spanNamed(t, recorder, "Query.student")
+	call := spanNamed(t, recorder, "students.StudentsService/GetStudent")
+
+	if operation.SpanKind() != trace.SpanKindServer || operation.Parent().IsValid() {
+		t.Errorf("operation span is not a root server span")
+	}
+	if field.Parent().SpanID() != operation.SpanContext().SpanID() {
+		t.Errorf("field span is not a child of the operation span")
+	}
+	if call.SpanKind() != trace.SpanKindClient || call.Parent().SpanID() != field.SpanContext().SpanID() {
+		t.Errorf("gRPC span is not a client span child of the field span")
+	}
+
+	// Fields read from the fetched student have no span of their own
+	for _, span := range recorder.Ended() {
+		if span.Name() == "Student.firstName" {
+			t.Errorf("got a span for a field without resolver")
+		}
+	}
+
+	// The trace context of the call travels next to the authorization header
+	students.mu.Lock()
+	defer students.mu.Unlock()
+
+	if got := students.md.Get("authorization"); len(got) != 1 || got[0] != "Bearer token" {
+		t.Errorf("got authorization metadata %v", got)
+	}
+	remote := otel.GetTextMapPropagator().Extract(context.Background(), metadataCarrier(students.md))
+	if sc := trace.SpanContextFromContext(remote); sc.SpanID() != call.SpanContext().SpanID() || sc.TraceID() != operation.SpanContext().TraceID() {
+		t.Errorf("traceparent metadata %v does not point at the gRPC span", students.md.Get("traceparent"))
+	}
+}
+
+func TestTracingWithoutFieldSpans(t *testing.T) {
+	recorder := recordSpans(t)
+	h, _ := tracedServer(t, TraceNoFields)
+
+	if res := execute(t, h, `{ student(id: "s1") { firstName } }`, nil); len(res.Errors) > 0 {
+		t.Fatalf("unexpected errors %v", res.Errors)
+	}
+
+	operation := spanNamed(t, recorder, "query")
+	call := spanNamed(t, recorder, "students.StudentsService/GetStudent")
+	if call.Parent().SpanID() != operation.SpanContext().SpanID() {
+		t.Errorf("gRPC span is not a child of the operation span")
+	}
+	if n := len(recorder.Ended()); n != 2 {
+		t.Errorf("got %d spans, want the operation and the gRPC call only", n)
+	}
+}
+
+func TestTracingContinuesClientTrace(t *testing.T) {
+	recorder := recordSpans(t)
+	h, _ := tracedServer(t, TraceResolverFields)
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	h = withHeader(h, "traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+
+	if res := execute(t, h, `{ student(id: "s1") { firstName } }`, nil); len(res.Errors) > 0 {
+		t.Fatalf("unexpected errors %v", res.Errors)
+	}
+
+	operation := spanNamed(t, recorder, "query")
+	if got := operation.SpanContext().TraceID().String(); got != traceID {
+		t.Errorf("operation joined trace %s, want the client's %s", got, traceID)
+	}
+	if !operation.Parent().IsRemote() {
+		t.Errorf("operation span is not a child of the client's span")
+	}
+}
+
+// withHeader sets a header on the requests passed to next
+func withHeader(next http.Handler, key, value string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Header.Set(key, value)
+		next.ServeHTTP(w, r)
+	})
+}
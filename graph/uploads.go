@@ -0,0 +1,129 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/BetterGR/api-gateway/graph/blobstore"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+const (
+	// defaultMaxUploadSize is the largest file accepted when UPLOAD_MAX_SIZE is not set
+	defaultMaxUploadSize = 20 << 20
+
+	// defaultAllowedUploadTypes are the content types accepted when UPLOAD_ALLOWED_TYPES is not set
+	defaultAllowedUploadTypes = "application/pdf,application/zip,text/plain,image/png,image/jpeg"
+)
+
+// UploadLimits restricts the files accepted by the gateway
+type UploadLimits struct {
+	// MaxSize is the largest accepted file, in bytes
+	MaxSize int64
+	// AllowedTypes are the accepted media types, any type is accepted when empty
+	AllowedTypes []string
+}
+
+// LoadUploadLimits builds the upload limits from the environment
+func LoadUploadLimits() (UploadLimits, error) {
+	maxSize, err := strconv.ParseInt(getEnvOrDefault("UPLOAD_MAX_SIZE", strconv.Itoa(defaultMaxUploadSize)), 10, 64)
+	if err != nil || maxSize <= 0 {
+		return UploadLimits{}, errors.New("UPLOAD_MAX_SIZE must be a positive number of bytes")
+	}
+
+	var allowedTypes []string
+	for _, t := range strings.Split(getEnvOrDefault("UPLOAD_ALLOWED_TYPES", defaultAllowedUploadTypes), ",") {
+		if t = strings.ToLower(strings.TrimSpace(t)); t != "" {
+			allowedTypes = append(allowedTypes, t)
+		}
+	}
+
+	return UploadLimits{MaxSize: maxSize, AllowedTypes: allowedTypes}, nil
+}
+
+// check rejects uploads that are too large or of a type that is not allowed
+func (l UploadLimits) check(ctx context.Context, upload graphql.Upload) error {
+	if l.MaxSize > 0 && upload.Size > l.MaxSize {
+		return badUploadError(ctx, fmt.Sprintf("file is larger than the maximum of %d bytes", l.MaxSize), map[string]any{
+			"maxSize": l.MaxSize,
+		})
+	}
+
+	if len(l.AllowedTypes) == 0 {
+		return nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(upload.ContentType)
+	if err != nil || !slices.Contains(l.AllowedTypes, mediaType) {
+		return badUploadError(ctx, fmt.Sprintf("files of type %q are not accepted", upload.ContentType), map[string]any{
+			"allowedTypes": l.AllowedTypes,
+		})
+	}
+
+	return nil
+}
+
+// badUploadError is returned when an uploaded file violates the upload limits
+func badUploadError(ctx context.Context, message string, extensions map[string]any) error {
+	extensions["code"] = ErrCodeBadUserInput
+
+	return &gqlerror.Error{
+		Message:    message,
+		Path:       graphql.GetPath(ctx),
+		Extensions: extensions,
+	}
+}
+
+// storeSubmissionFile validates an uploaded submission and streams it to the blob store
+func (r *Resolver) storeSubmissionFile(ctx context.Context, homeworkID, studentID string, upload graphql.Upload) (blobstore.Object, error) {
+	if err := r.UploadLimits.check(ctx, upload); err != nil {
+		return blobstore.Object{}, err
+	}
+
+	if r.Blobs == nil {
+		return blobstore.Object{}, errors.New("no blob store is configured for uploads")
+	}
+
+	// Keep every attempt, resubmissions must not overwrite the file of an earlier submission
+	key := path.Join("submissions", sanitizeFilename(homeworkID), sanitizeFilename(studentID),
+		strconv.FormatInt(time.Now().UnixNano(), 10)+"-"+sanitizeFilename(upload.Filename))
+
+	return r.Blobs.Put(ctx, key, upload.File)
+}
+
+// discardSubmissionFile deletes a stored file whose submission could not be recorded
+func (r *Resolver) discardSubmissionFile(ctx context.Context, object blobstore.Object) {
+	// Clean up even when the request itself was cancelled
+	if err := r.Blobs.Delete(context.WithoutCancel(ctx), object.Ref); err != nil {
+		r.logger(ctx).Error("failed to delete orphaned upload", "ref", object.Ref, "error", err)
+	}
+}
+
+// sanitizeFilename keeps the base name of a client-provided name, stripped of characters
+// that are unsafe in blob store keys
+func sanitizeFilename(filename string) string {
+	name := path.Base(strings.ReplaceAll(filename, "\\", "/"))
+
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+
+	if strings.Trim(name, ".") == "" {
+		return "file"
+	}
+
+	return name
+}
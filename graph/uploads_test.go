@@ -0,0 +1,132 @@
+package graph
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/BetterGR/api-gateway/graph/blobstore"
+)
+
+// testUpload is an uploaded file holding content
+func testUpload(filename, contentType, content string) graphql.Upload {
+	return graphql.Upload{
+		File:        strings.NewReader(content),
+		Filename:    filename,
+		Size:        int64(len(content)),
+		ContentType: contentType,
+	}
+}
+
+func TestUploadLimits(t *testing.T) {
+	limits := UploadLimits{MaxSize: 10, AllowedTypes: []string{"application/pdf", "text/plain"}}
+
+	tests := []struct {
+		name     string
+		limits   UploadLimits
+		upload   graphql.Upload
+		wantCode string
+	}{
+		{name: "allowed", limits: limits, upload: testUpload("a.pdf", "application/pdf", "0123456789")},
+		{name: "allowed with parameters", limits: limits, upload: testUpload("a.txt", "text/plain; charset=utf-8", "hello")},
+		{name: "too large", limits: limits, upload: testUpload("a.pdf", "application/pdf", "01234567890"), wantCode: ErrCodeBadUserInput},
+		{name: "type not allowed", limits: limits, upload: testUpload("a.exe", "application/x-msdownload", "MZ"), wantCode: ErrCodeBadUserInput},
+		{name: "invalid type", limits: limits, upload: testUpload("a.pdf", "pdf;;", "x"), wantCode: ErrCodeBadUserInput},
+		{name: "missing type", limits: limits, upload: testUpload("a.pdf", "", "x"), wantCode: ErrCodeBadUserInput},
+		{name: "any type when none are listed", limits: UploadLimits{MaxSize: 10}, upload: testUpload("a.exe", "application/x-msdownload", "MZ")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := errorCode(tt.limits.check(context.Background(), tt.upload)); code != tt.wantCode {
+				t.Errorf("got code %q, want %q", code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestSanitizeFilename(t *testing.T) {
+	tests := map[string]string{
+		"answer.pdf":                 "answer.pdf",
+		"../../etc/passwd":           "passwd",
+		`..\..\windows\win.ini`:      "win.ini",
+		"my answer (final)?.pdf":     "my_answer__final__.pdf",
+		"תשובה.pdf":                  "_____.pdf",
+		"..":                         "file",
+		"":                           "file",
+		"submissions/":               "submissions",
+		"/":                          "_",
+		"report.pdf\x00.exe":         "report.pdf_.exe",
+		"../submissions/s2/answer.p": "answer.p",
+	}
+
+	for filename, want := range tests {
+		if got := sanitizeFilename(filename); got != want {
+			t.Errorf("sanitizeFilename(%q) = %q, want %q", filename, got, want)
+		}
+	}
+}
+
+func TestStoreSubmissionFile(t *testing.T) {
+	dir := t.TempDir()
+	store, err := blobstore.NewLocalStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &Resolver{Blobs: store, UploadLimits: UploadLimits{MaxSize: 10, AllowedTypes: []string{"application/pdf"}}}
+
+	// Client-provided names cannot place the file outside the directory of the submission
+	obj, err := r.storeSubmissionFile(context.Background(), "../h1", "s1", testUpload("../../answer.pdf", "application/pdf", "%PDF-1.7"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir, name := filepath.Split(obj.Ref); dir != "submissions/h1/s1/" || !strings.HasSuffix(name, "-answer.pdf") {
+		t.Errorf("got ref %q, want a file of submissions/h1/s1", obj.Ref)
+	}
+	if obj.Size != 8 || obj.Checksum == "" {
+		t.Errorf("got %+v, want the size and checksum of the file", obj)
+	}
+
+	// Files over the limits are rejected before anything is stored
+	if _, err := r.storeSubmissionFile(context.Background(), "h1", "s1", testUpload("big.pdf", "application/pdf", "01234567890")); errorCode(err) != ErrCodeBadUserInput {
+		t.Errorf("got %v, want BAD_USER_INPUT", err)
+	}
+
+	// Files of submissions that could not be recorded are deleted
+	r.discardSubmissionFile(context.Background(), obj)
+	var files []string
+	if err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			files = append(files, path)
+		}
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 0 {
+		t.Errorf("got files %v, want none", files)
+	}
+}
+
+func TestLoadUploadLimits(t *testing.T) {
+	t.Setenv("UPLOAD_MAX_SIZE", "1024")
+	t.Setenv("UPLOAD_ALLOWED_TYPES", " Application/PDF , ,text/plain")
+
+	limits, err := LoadUploadLimits()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limits.MaxSize != 1024 || strings.Join(limits.AllowedTypes, ",") != "application/pdf,text/plain" {
+		t.Errorf("got %+v", limits)
+	}
+
+	for _, size := range []string{"0", "-1", "20MB"} {
+		t.Setenv("UPLOAD_MAX_SIZE", size)
+		if _, err := LoadUploadLimits(); err == nil {
+			t.Errorf("accepted UPLOAD_MAX_SIZE=%s", size)
+		}
+	}
+}
@@ -1,10 +1,13 @@
 package graph
 
 import (
-	"fmt"
+	"time"
 
 	"github.com/BetterGR/api-gateway/graph/model"
+	coursespb "github.com/BetterGR/courses-microservice/protos"
 	gradespb "github.com/BetterGR/grades-microservice/protos"
+	staffpb "github.com/BetterGR/staff-microservice/protos"
+	studentspb "github.com/BetterGR/students-microservice/protos"
 )
 
 func convertGradesToGraphQL(grades []*gradespb.SingleGrade) []*model.Grade {
@@ -15,9 +18,6 @@ func convertGradesToGraphQL(grades []*gradespb.SingleGrade) []*model.Grade {
 		timestamp := g.GradedBy
 		gradedBy := "System" // Default value since we're using the field for timestamp
 
-		// Debug: Print what we're receiving
-		fmt.Printf("DEBUG: Received grade with GradedBy (timestamp): %s\n", timestamp)
-
 		result[i] = &model.Grade{
 			ID:         g.GradeID,
 			StudentID:  g.StudentID,
@@ -35,3 +35,70 @@ func convertGradesToGraphQL(grades []*gradespb.SingleGrade) []*model.Grade {
 
 	return result
 }
+
+func convertStudentToGraphQL(s *studentspb.Student) *model.Student {
+	// The students microservice does not expose timestamps yet
+	now := time.Now().Format(time.RFC3339)
+
+	return &model.Student{
+		ID:          s.StudentID,
+		FirstName:   s.FirstName,
+		LastName:    s.LastName,
+		Email:       s.Email,
+		PhoneNumber: s.PhoneNumber,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+}
+
+func convertStaffToGraphQL(s *staffpb.StaffMember) *model.Staff {
+	// The staff microservice does not expose timestamps yet
+	now := time.Now().Format(time.RFC3339)
+
+	return &model.Staff{
+		ID:          s.StaffID,
+		FirstName:   s.FirstName,
+		LastName:    s.LastName,
+		Email:       s.Email,
+		PhoneNumber: s.PhoneNumber,
+		Title:       &s.Title,
+		Office:      &s.Office,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+}
+
+func convertCourseToGraphQL(c *coursespb.Course) *model.Course {
+	// The courses microservice does not expose timestamps yet
+	now := time.Now().Format(time.RFC3339)
+
+	// Nested lists (staff, students, grades, ...) are populated by the Course field resolvers
+	return &model.Course{
+		ID:          c.CourseID,
+		Name:        c.CourseName,
+		Semester:    c.Semester,
+		Description: &c.Description,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+}
+
+func convertAnnouncementsToGraphQL(courseID string, announcements []*coursespb.Announcement) []*model.Announcement {
+	result := make([]*model.Announcement, len(announcements))
+
+	// The courses microservice does not expose announcement timestamps yet
+	now := time.Now().Format(time.RFC3339)
+
+	for i, a := range announcements {
+		result[i] = &model.Announcement{
+			ID:        a.AnnouncementID,
+			CourseID:  courseID,
+			Title:     a.AnnouncementTitle,
+			Content:   a.AnnouncementContent,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+	}
+
+	return result
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
@@ -15,65 +16,165 @@ import (
 	"github.com/99designs/gqlgen/graphql/handler/transport"
 	"github.com/99designs/gqlgen/graphql/playground"
 	"github.com/BetterGR/api-gateway/graph"
+	"github.com/BetterGR/api-gateway/graph/model"
+	"github.com/BetterGR/api-gateway/graph/ratelimit"
+	"github.com/gorilla/websocket"
 	"github.com/joho/godotenv"
 	"github.com/vektah/gqlparser/v2/ast"
 )
 
-const defaultPort = "8080"
+const (
+	defaultPort = "8080"
 
-func init() {
+	// multipartOverhead is how far a multipart body may exceed the largest accepted file
+	multipartOverhead = 1 << 20
+
+	// multipartMaxMemory is how much of a multipart body is kept in memory while parsing
+	multipartMaxMemory = 8 << 20
+)
+
+func main() {
 	// Load .env file if it exists
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found or error loading it. Using environment variables.")
+	envErr := godotenv.Load()
+
+	// Set up structured logging, also used by the packages logging through the log package
+	logSettings, err := graph.LoadLogSettings()
+	if err != nil {
+		log.Fatalf("Failed to load log settings: %v", err)
+	}
+	logger := graph.NewLogger(os.Stdout, logSettings)
+	slog.SetDefault(logger)
+
+	if envErr != nil {
+		logger.Info("No .env file found or error loading it. Using environment variables.")
 	}
-}
 
-func main() {
 	// Get API Gateway configuration
 	port := os.Getenv("API_GATEWAY_PORT")
 	if port == "" {
 		port = defaultPort
 	}
 
+	// Export traces of the operations and of the calls they make to the microservices
+	tracingSettings, err := graph.LoadTracingSettings()
+	if err != nil {
+		fatal(logger, "Failed to load tracing settings", err)
+	}
+	shutdownTracing, err := graph.SetupTracing(context.Background(), tracingSettings)
+	if err != nil {
+		fatal(logger, "Failed to set up tracing", err)
+	}
+
 	// Initialize resolver with gRPC clients
-	resolver, err := graph.NewResolver()
+	resolver, err := graph.NewResolver(logger)
 	if err != nil {
-		log.Fatalf("Failed to initialize resolver: %v", err)
+		fatal(logger, "Failed to initialize resolver", err)
 	}
 
 	// Ensure we close gRPC connections on shutdown
 	defer resolver.Close()
 
-	srv := handler.New(graph.NewExecutableSchema(graph.Config{Resolvers: resolver}))
+	// Reject operations nested too deeply or fanning out to too many backend calls
+	queryLimits, err := graph.LoadQueryLimits()
+	if err != nil {
+		fatal(logger, "Failed to load query limits", err)
+	}
+
+	// Limit how often each client may run queries and mutations
+	rateLimits, err := graph.LoadRateLimits()
+	if err != nil {
+		fatal(logger, "Failed to load rate limits", err)
+	}
+	rateLimiter := graph.NewRateLimiter(ratelimit.NewMemoryStore(), rateLimits)
+
+	srv := handler.New(graph.NewExecutableSchema(graph.Config{
+		Resolvers:  resolver,
+		Directives: graph.NewDirectives(),
+		Complexity: graph.NewComplexity(),
+	}))
 
+	// Verify access tokens against the Keycloak realm when it is configured
+	authConfig, err := graph.LoadAuthConfig()
+	if err != nil {
+		fatal(logger, "Failed to load auth settings", err)
+	}
+	var tokenValidator *graph.TokenValidator
+	if authConfig != nil {
+		tokenValidator = graph.NewTokenValidator(*authConfig, nil)
+	} else {
+		logger.Warn("KEYCLOAK_URL is not set. Access tokens will not be verified and protected fields will be rejected.")
+	}
+
+	// Subscriptions are served over WebSocket (graphql-ws and graphql-transport-ws protocols)
+	srv.AddTransport(transport.Websocket{
+		KeepAlivePingInterval: 10 * time.Second,
+		InitFunc:              graph.WebsocketInitFunc(tokenValidator),
+		Upgrader: websocket.Upgrader{
+			// Credentials travel in the connection_init payload rather than in cookies,
+			// so connections from any origin can be accepted
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+	})
 	srv.AddTransport(transport.Options{})
 	srv.AddTransport(transport.GET{})
 	srv.AddTransport(transport.POST{})
 
+	// File uploads follow the GraphQL multipart request spec. The body may exceed the largest
+	// accepted file by the size of the operations and map fields; anything beyond the in-memory
+	// threshold is buffered in temporary files.
+	srv.AddTransport(transport.MultipartForm{
+		MaxUploadSize: resolver.UploadLimits.MaxSize + multipartOverhead,
+		MaxMemory:     multipartMaxMemory,
+	})
+
 	srv.SetQueryCache(lru.New[*ast.QueryDocument](1000))
 
+	// Translate backend errors into typed GraphQL errors, hiding their details in production
+	production := os.Getenv("APP_ENV") == "production"
+	srv.SetErrorPresenter(resolver.Metrics.ErrorPresenter(graph.NewErrorPresenter(production)))
+
 	srv.Use(extension.Introspection{})
+	srv.Use(rateLimiter)
+	srv.Use(graph.NewQueryLimiter(queryLimits))
 	srv.Use(extension.AutomaticPersistedQuery{
-		Cache: lru.New[string](100),
+		Cache: resolver.Metrics.InstrumentAPQCache(lru.New[string](100)),
 	})
+	srv.Use(resolver.Metrics)
+	srv.Use(graph.NewTracing(tracingSettings.Fields))
+	srv.Use(graph.RequestLogging{})
+	srv.Use(graph.NewLoaderExtension(resolver))
 
 	// Set up GraphQL playground
 	http.Handle("/", playground.Handler("GraphQL playground", "/query"))
 
-	// Apply the auth middleware to the query endpoint
-	http.Handle("/query", graph.AuthMiddleware(srv))
+	// Continue the trace of the client, then apply the auth middleware and the rate limiter to
+	// the query endpoint
+	http.Handle("/query", graph.TraceContextMiddleware(
+		graph.AuthMiddleware(tokenValidator, rateLimiter.Middleware(srv)),
+	))
+
+	// Prometheus metrics
+	http.Handle("/metrics", resolver.Metrics.Handler())
+
+	// Liveness and readiness probes
+	http.Handle("/healthz", graph.LivenessHandler())
+	http.Handle("/readyz", resolver.Health.ReadinessHandler())
+
+	// Report the state of the microservices and of the circuit breakers to admins
+	http.Handle("/status", graph.AuthMiddleware(tokenValidator, graph.RequireRole(model.RoleAdmin, resolver.Health)))
+	http.Handle("/admin/breakers", graph.AuthMiddleware(tokenValidator, graph.RequireRole(model.RoleAdmin, resolver.Breakers)))
 
-	// Create an HTTP server
+	// Create an HTTP server, logging every request it serves
 	httpServer := &http.Server{
 		Addr:    ":" + port,
-		Handler: nil, // Use DefaultServeMux
+		Handler: graph.RequestLogMiddleware(logger, []string{"/healthz", "/readyz", "/metrics"}, http.DefaultServeMux),
 	}
 
 	// Handle graceful shutdown
 	go func() {
-		log.Printf("connect to http://localhost:%s/ for GraphQL playground", port)
+		logger.Info("Serving the GraphQL playground", "url", "http://localhost:"+port+"/")
 		if err := httpServer.ListenAndServe(); err != http.ErrServerClosed {
-			log.Fatalf("HTTP server error: %v", err)
+			fatal(logger, "HTTP server error", err)
 		}
 	}()
 
@@ -82,7 +183,7 @@ func main() {
 	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
 	<-stop
 
-	log.Println("Shutting down server...")
+	logger.Info("Shutting down server...")
 
 	// Create a deadline to wait for current connections to complete
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
@@ -90,8 +191,19 @@ func main() {
 
 	// Shutdown the HTTP server
 	if err := httpServer.Shutdown(ctx); err != nil {
-		log.Fatalf("Server forced to shutdown: %v", err)
+		fatal(logger, "Server forced to shutdown", err)
 	}
 
-	log.Println("Server gracefully stopped")
+	// Flush the spans that have not been exported yet
+	if err := shutdownTracing(ctx); err != nil {
+		logger.Error("Failed to flush traces", "error", err)
+	}
+
+	logger.Info("Server gracefully stopped")
+}
+
+// fatal logs err and exits
+func fatal(logger *slog.Logger, msg string, err error) {
+	logger.Error(msg, "error", err)
+	os.Exit(1)
 }